@@ -5,4 +5,74 @@ import "errors"
 var (
 	//ErrDataOverflow error
 	ErrDataOverflow = errors.New("Data overflow")
+	//ErrFrameOverflow error
+	ErrFrameOverflow = errors.New("Frame data overflow, fragment is required")
+	//ErrDataNameOverflow error
+	ErrDataNameOverflow = errors.New("Data name overflow")
+	//ErrDataNameEmpty error
+	ErrDataNameEmpty = errors.New("Data name empty")
+	//ErrDataTruncated error
+	ErrDataTruncated = errors.New("Data truncated")
+	//ErrDataCorrupted error
+	ErrDataCorrupted = errors.New("Data corrupted")
+	//ErrCompressUnsupported error
+	ErrCompressUnsupported = errors.New("Compress unsupported")
+	//ErrFragmentUnsupported error
+	ErrFragmentUnsupported = errors.New("Fragment unsupported")
+	//ErrCipherUnsupported error
+	ErrCipherUnsupported = errors.New("Cipher unsupported")
+	//ErrFrameAuthFailed error
+	ErrFrameAuthFailed = errors.New("Frame authentication failed")
+	//ErrHandshakeMalformed error
+	ErrHandshakeMalformed = errors.New("Handshake malformed")
+	//ErrHandshakeRejected error
+	ErrHandshakeRejected = errors.New("Handshake rejected")
+	//ErrMessageIDOverflow error
+	ErrMessageIDOverflow = errors.New("Message id overflow")
+	//ErrMessageIDConflict error
+	ErrMessageIDConflict = errors.New("Message id conflict")
+	//ErrMessageRegistered error
+	ErrMessageRegistered = errors.New("Message registered")
+	//ErrMessageUnregistered error
+	ErrMessageUnregistered = errors.New("Message unregistered")
+	//ErrMessageUndefined error
+	ErrMessageUndefined = errors.New("Message undefined")
+	//ErrTLSUnsupported error
+	ErrTLSUnsupported = errors.New("TLS unsupported")
+	//ErrTLSUnconfigured error
+	ErrTLSUnconfigured = errors.New("TLS unconfigured")
+	//ErrListenUnsupported error
+	ErrListenUnsupported = errors.New("Listen unsupported")
+	//ErrClientUndefined error
+	ErrClientUndefined = errors.New("Client undefined")
+	//ErrClientHandshaking error
+	ErrClientHandshaking = errors.New("Client handshaking")
+	//ErrClientFull error
+	ErrClientFull = errors.New("Client full")
+	//ErrTokenMalformed error
+	ErrTokenMalformed = errors.New("Token malformed")
+	//ErrTokenSignature error
+	ErrTokenSignature = errors.New("Token signature mismatch")
+	//ErrTokenExpired error
+	ErrTokenExpired = errors.New("Token expired")
+	//ErrStateTransition error
+	ErrStateTransition = errors.New("State transition invalid")
+	//ErrClientBanned error
+	ErrClientBanned = errors.New("Client banned")
+	//ErrClientIPFull error
+	ErrClientIPFull = errors.New("Client ip full")
+	//ErrAcceptLimited error
+	ErrAcceptLimited = errors.New("Accept limited")
+	//ErrBanMalformed error
+	ErrBanMalformed = errors.New("Ban address malformed")
+	//ErrConnectClosed error
+	ErrConnectClosed = errors.New("Connect closed")
+	//ErrResumeBuffer error
+	ErrResumeBuffer = errors.New("Resume buffer invalid")
+	//ErrClientOutFull error
+	ErrClientOutFull = errors.New("Client out queue full")
+	//ErrSessionExpired error
+	ErrSessionExpired = errors.New("Session expired")
+	//ErrAgreementUndefined error
+	ErrAgreementUndefined = errors.New("Agreement undefined")
 )
@@ -0,0 +1,359 @@
+package gateway
+
+import (
+	stdnet "net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+//AutoBan doc
+//@Summary ban an ip of repeated protocol errors, they are malformed frames,
+//         cipher failures and login tokens of bad signature
+//@Member  protocol errors to ban, 0 is never
+//@Member  time window of errors in millisecond
+//@Member  ban time in millisecond, 0 is forever
+type AutoBan struct {
+	Errors   int
+	Window   int64
+	Duration int64
+}
+
+type ban struct {
+	_net    *stdnet.IPNet
+	_expire int64
+}
+
+type fault struct {
+	_count int
+	_first int64
+}
+
+//admission connection admission control of server, time is in millisecond.
+//Banned ips are looked up by key, only banned cidrs are scanned
+type admission struct {
+	_maxConn  int
+	_rate     RateLimit
+	_autoBan  AutoBan
+	_conns    map[string]int
+	_attempts map[string]*TokenBucket
+	_faults   map[string]*fault
+	_ips      map[string]*ban
+	_nets     map[string]*ban
+	_sync     sync.Mutex
+}
+
+func newAdmission(maxConn int, rate RateLimit, autoBan AutoBan) *admission {
+	return &admission{_maxConn: maxConn,
+		_rate:     rate,
+		_autoBan:  autoBan,
+		_conns:    make(map[string]int),
+		_attempts: make(map[string]*TokenBucket),
+		_faults:   make(map[string]*fault),
+		_ips:      make(map[string]*ban),
+		_nets:     make(map[string]*ban)}
+}
+
+//parseIP returns ip of a remote address
+func parseIP(addr string) stdnet.IP {
+	if host, _, err := stdnet.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return stdnet.ParseIP(addr)
+}
+
+//parseBan returns network of an ip or cidr
+func parseBan(addr string) (*stdnet.IPNet, error) {
+	if strings.Contains(addr, "/") {
+		_, n, err := stdnet.ParseCIDR(addr)
+		if err != nil {
+			return nil, code.ErrBanMalformed
+		}
+		return n, nil
+	}
+
+	ip := stdnet.ParseIP(addr)
+	if ip == nil {
+		return nil, code.ErrBanMalformed
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return &stdnet.IPNet{IP: v4, Mask: stdnet.CIDRMask(32, 32)}, nil
+	}
+	return &stdnet.IPNet{IP: ip, Mask: stdnet.CIDRMask(128, 128)}, nil
+}
+
+//isFault returns true when the error of a client is counted by auto ban,
+//errors of clients out of date or of messages unknown are not
+func isFault(err error) bool {
+	switch err {
+	case code.ErrDataOverflow,
+		code.ErrDataNameOverflow,
+		code.ErrDataNameEmpty,
+		code.ErrDataTruncated,
+		code.ErrDataCorrupted,
+		code.ErrHandshakeMalformed,
+		code.ErrFrameAuthFailed,
+		code.ErrTokenMalformed,
+		code.ErrTokenSignature:
+		return true
+	}
+	return false
+}
+
+//banKey returns key of a network in ban list, true when it is an ip
+func banKey(n *stdnet.IPNet) (string, bool) {
+	if ones, bits := n.Mask.Size(); ones == bits {
+		return n.IP.String(), true
+	}
+	return n.String(), false
+}
+
+//admit count a connection of ip, it is rejected when the ip is banned or over limits
+func (slf *admission) admit(ip stdnet.IP, now int64) error {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	if slf.isBanned(ip, now) {
+		return code.ErrClientBanned
+	}
+
+	key := ip.String()
+	if slf._rate.Rate > 0 {
+		b, ok := slf._attempts[key]
+		if !ok {
+			b = NewTokenBucket(slf._rate)
+			slf._attempts[key] = b
+		}
+
+		if !b.Take(now * int64(time.Millisecond)) {
+			return code.ErrAcceptLimited
+		}
+	}
+
+	if slf._maxConn > 0 && slf._conns[key] >= slf._maxConn {
+		return code.ErrClientIPFull
+	}
+
+	slf._conns[key]++
+	return nil
+}
+
+//release uncount a connection of ip
+func (slf *admission) release(ip string) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	if n := slf._conns[ip]; n > 1 {
+		slf._conns[ip] = n - 1
+	} else {
+		delete(slf._conns, ip)
+	}
+}
+
+//fault count a protocol error of ip, returns true when the ip is banned
+func (slf *admission) fault(ip stdnet.IP, now int64) bool {
+	if slf._autoBan.Errors <= 0 || ip == nil {
+		return false
+	}
+
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	key := ip.String()
+	f, ok := slf._faults[key]
+	if !ok || now-f._first > slf._autoBan.Window {
+		f = &fault{_first: now}
+		slf._faults[key] = f
+	}
+
+	if f._count++; f._count < slf._autoBan.Errors {
+		return false
+	}
+
+	delete(slf._faults, key)
+	n, _ := parseBan(key)
+	slf.ban(n, slf._autoBan.Duration, now)
+	return true
+}
+
+//ban add a network to ban list, 0 duration is forever. The lock is held by caller
+func (slf *admission) ban(n *stdnet.IPNet, duration int64, now int64) {
+	b := &ban{_net: n}
+	if duration > 0 {
+		b._expire = now + duration
+	}
+
+	if key, isIP := banKey(n); isIP {
+		slf._ips[key] = b
+	} else {
+		slf._nets[key] = b
+	}
+}
+
+//unban remove a network from ban list. The lock is held by caller
+func (slf *admission) unban(n *stdnet.IPNet) {
+	if key, isIP := banKey(n); isIP {
+		delete(slf._ips, key)
+	} else {
+		delete(slf._nets, key)
+	}
+}
+
+//isBanned returns true when ip is in ban list. The lock is held by caller
+func (slf *admission) isBanned(ip stdnet.IP, now int64) bool {
+	key := ip.String()
+	if b, ok := slf._ips[key]; ok {
+		if b._expire == 0 || b._expire > now {
+			return true
+		}
+		delete(slf._ips, key)
+	}
+
+	for k, b := range slf._nets {
+		if b._expire != 0 && b._expire <= now {
+			delete(slf._nets, k)
+			continue
+		}
+
+		if b._net.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//expireBans drop expired bans of the list
+func expireBans(bans map[string]*ban, now int64) {
+	for k, b := range bans {
+		if b._expire != 0 && b._expire <= now {
+			delete(bans, k)
+		}
+	}
+}
+
+//expire drop expired bans, idle attempt buckets and faults out of window
+func (slf *admission) expire(now int64) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	expireBans(slf._ips, now)
+	expireBans(slf._nets, now)
+
+	for k, b := range slf._attempts {
+		//a full bucket is same as a new one
+		if float64(now*int64(time.Millisecond)-b._last)*b._rate/float64(time.Second) >= b._burst {
+			delete(slf._attempts, k)
+		}
+	}
+
+	for k, f := range slf._faults {
+		if now-f._first > slf._autoBan.Window {
+			delete(slf._faults, k)
+		}
+	}
+}
+
+//admit check the remote address of a accepted client
+func (slf *Server) admit(c *client) error {
+	ip := parseIP(c.GetAddr())
+	if ip == nil {
+		return nil
+	}
+
+	if err := slf._admission.admit(ip, time.Now().UnixNano()/int64(time.Millisecond)); err != nil {
+		atomic.AddUint64(&slf._metrics._acceptRejected, 1)
+		return err
+	}
+	c._ip = ip.String()
+	return nil
+}
+
+//fault count a protocol error of client, clients of the ip are closed when it is banned.
+//Errors not counted by auto ban are ignored
+func (slf *Server) fault(c *client, err error) {
+	if !isFault(err) {
+		return
+	}
+
+	ip := parseIP(c.GetAddr())
+	if slf._admission.fault(ip, time.Now().UnixNano()/int64(time.Millisecond)) {
+		atomic.AddUint64(&slf._metrics._autoBanned, 1)
+		n, _ := parseBan(ip.String())
+		c.LogError("client %s => %d banned by protocol errors", c.GetAddr(), c.GetSocket())
+		slf.closeBanned(n)
+	}
+}
+
+//closeBanned close clients in the network
+func (slf *Server) closeBanned(n *stdnet.IPNet) {
+	for _, h := range slf._group.GetHandles() {
+		c := slf._group.Grap(h)
+		if c == nil {
+			continue
+		}
+
+		if ip := stdnet.ParseIP(c.(*client)._ip); ip != nil && n.Contains(ip) {
+			c.(*client).close()
+		}
+		slf._group.Release(c)
+	}
+}
+
+//Ban doc
+//@Summary ban an ip or cidr, connected clients of it are closed
+//@Param  ip or cidr, 10.0.0.1 or 10.0.0.0/8
+//@Param  ban time in millisecond, 0 is forever
+//@Return error
+func (slf *Server) Ban(addr string, duration int64) error {
+	n, err := parseBan(addr)
+	if err != nil {
+		return err
+	}
+
+	slf._admission._sync.Lock()
+	slf._admission.ban(n, duration, time.Now().UnixNano()/int64(time.Millisecond))
+	slf._admission._sync.Unlock()
+
+	slf.closeBanned(n)
+	return nil
+}
+
+//Unban doc
+//@Summary remove an ip or cidr from ban list
+//@Param  ip or cidr, same as banned
+//@Return error
+func (slf *Server) Unban(addr string) error {
+	n, err := parseBan(addr)
+	if err != nil {
+		return err
+	}
+
+	slf._admission._sync.Lock()
+	defer slf._admission._sync.Unlock()
+	slf._admission.unban(n)
+	return nil
+}
+
+//Bans doc
+//@Summary Returns ip networks of ban list
+func (slf *Server) Bans() []string {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	slf._admission._sync.Lock()
+	defer slf._admission._sync.Unlock()
+
+	result := make([]string, 0, len(slf._admission._ips)+len(slf._admission._nets))
+	for _, bans := range []map[string]*ban{slf._admission._ips, slf._admission._nets} {
+		for _, b := range bans {
+			if b._expire == 0 || b._expire > now {
+				result = append(result, b._net.String())
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,181 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+const (
+	//LoginOK login accepted
+	LoginOK = 0
+	//LoginInvalid token malformed or signature mismatch
+	LoginInvalid = 1
+	//LoginExpired token expired
+	LoginExpired = 2
+)
+
+const (
+	//token format version
+	constTokenVersion = 1
+	//version + user id + auth level + expire
+	constTokenBodyByte = 25
+	//hmac-sha256 signature length
+	constTokenSignByte = sha256.Size
+)
+
+/*****************************************************|
+|  8 Bit    |  64 Bit   |  64 Bit  |  64 Bit  | 256 Bit  |
+|-----------|-----------|----------|----------|----------|
+|  Version  |  User ID  |  Auth    |  Expire  |  HMAC    |
+******************************************************/
+
+//Authenticator doc
+//@Summary validate login messages of client at gateway
+//@Member IsLogin returns true when the message is a login message of authenticator
+//@Member Authenticate returns user id, auth level and expire time in millisecond,
+//         the auth level should be greater than 0
+type Authenticator interface {
+	IsLogin(msg interface{}) bool
+	Authenticate(handle uint64, msg interface{}) (uint64, int64, int64, error)
+}
+
+//SignToken doc
+//@Summary Returns a signed login token
+//@Param  signing key
+//@Param  user id
+//@Param  auth level
+//@Param  expire time in millisecond
+//@Return []byte
+func SignToken(key []byte, userID uint64, auth int64, expire int64) []byte {
+	result := make([]byte, constTokenBodyByte, constTokenBodyByte+constTokenSignByte)
+	result[0] = constTokenVersion
+	binary.BigEndian.PutUint64(result[1:], userID)
+	binary.BigEndian.PutUint64(result[9:], uint64(auth))
+	binary.BigEndian.PutUint64(result[17:], uint64(expire))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(result)
+	return mac.Sum(result)
+}
+
+//VerifyToken doc
+//@Summary verify a signed login token
+//@Param  signing key
+//@Param  token
+//@Param  now in millisecond
+//@Return user id
+//@Return auth level
+//@Return expire time in millisecond
+//@Return error
+func VerifyToken(key []byte, token []byte, now int64) (uint64, int64, int64, error) {
+	if len(token) != constTokenBodyByte+constTokenSignByte || token[0] != constTokenVersion {
+		return 0, 0, 0, code.ErrTokenMalformed
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(token[:constTokenBodyByte])
+	if !hmac.Equal(mac.Sum(nil), token[constTokenBodyByte:]) {
+		return 0, 0, 0, code.ErrTokenSignature
+	}
+
+	expire := int64(binary.BigEndian.Uint64(token[17:]))
+	if expire <= now {
+		return 0, 0, 0, code.ErrTokenExpired
+	}
+
+	return binary.BigEndian.Uint64(token[1:]), int64(binary.BigEndian.Uint64(token[9:])), expire, nil
+}
+
+//TokenAuthenticator doc
+//@Summary authenticate LoginReq of a token signed by SignToken
+//@Member  signing keys, old keys are accepted while rotating
+type TokenAuthenticator struct {
+	Keys [][]byte
+}
+
+//NewTokenAuthenticator doc
+//@Summary Returns a token authenticator
+//@Param  signing keys
+func NewTokenAuthenticator(keys ...[]byte) *TokenAuthenticator {
+	return &TokenAuthenticator{Keys: keys}
+}
+
+//IsLogin doc
+//@Summary Returns true when the message is a LoginReq
+func (slf *TokenAuthenticator) IsLogin(msg interface{}) bool {
+	_, ok := msg.(*LoginReq)
+	return ok
+}
+
+//Authenticate doc
+//@Summary verify token of LoginReq
+func (slf *TokenAuthenticator) Authenticate(handle uint64, msg interface{}) (uint64, int64, int64, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	err := code.ErrTokenSignature
+	for _, key := range slf.Keys {
+		userID, auth, expire, e := VerifyToken(key, msg.(*LoginReq).Token, now)
+		if e == nil {
+			return userID, auth, expire, nil
+		} else if e != code.ErrTokenSignature {
+			return 0, 0, 0, e
+		}
+		err = e
+	}
+	return 0, 0, 0, err
+}
+
+//login authenticate a login message of client, the result is sent to client
+func (slf *Server) login(c *client, msg interface{}) {
+	userID, auth, expire, err := slf._authenticator.Authenticate(c.GetID(), msg)
+	if err == nil && auth <= 0 {
+		err = code.ErrTokenMalformed
+	}
+
+	if err != nil {
+		c.LogError("client %s => %d login %s", c.GetAddr(), c.GetSocket(), err.Error())
+		slf.fault(c, err)
+		rsp := &LoginRsp{Code: LoginInvalid}
+		if err == code.ErrTokenExpired {
+			rsp.Code = LoginExpired
+		}
+
+		if err = slf.sendTo(c, rsp); err != nil {
+			c.LogError("response to client %s => %d %s", c.GetAddr(), c.GetSocket(), err.Error())
+		}
+		return
+	}
+
+	atomic.StoreUint64(&c._userID, userID)
+	if err = slf.authenticate(c, auth); err != nil {
+		c.LogError("client %s => %d login %s", c.GetAddr(), c.GetSocket(), err.Error())
+		return
+	}
+
+	if err = slf.sendTo(c, &LoginRsp{Code: LoginOK, UserId: userID, Auth: auth, Expire: expire}); err != nil {
+		c.LogError("response to client %s => %d %s", c.GetAddr(), c.GetSocket(), err.Error())
+		return
+	}
+
+	if err = slf.issueToken(c); err != nil {
+		c.LogError("resume token of client %s => %d %s", c.GetAddr(), c.GetSocket(), err.Error())
+	}
+}
+
+//GetUserID doc
+//@Summary Returns user id of client authenticated by authenticator
+//@Param  client handle
+//@Return uint64
+//@Return error
+func (slf *Server) GetUserID(handle uint64) (uint64, error) {
+	c := slf._group.Grap(handle)
+	if c == nil {
+		return 0, code.ErrClientUndefined
+	}
+	defer slf._group.Release(c)
+	return atomic.LoadUint64(&c.(*client)._userID), nil
+}
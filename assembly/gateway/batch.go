@@ -0,0 +1,158 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+/*************************************************************|
+|  Varint   |  (N) Bit  |  Varint      |  (N) Bit  |  ...     |
+|-----------|-----------|--------------|-----------|----------|
+|  Name     |  Name     |  Data        |  Data     |  Next    |
+|  Length   |           |  Length      |           |  Entry   |
+**************************************************************/
+
+//PackBatch doc
+//@Summary pack agreement names and datas into a batch container,
+//         the container is encoded as a frame with empty agreement name
+//@Param  agreement names
+//@Param  agreement datas
+//@Return batch container
+func PackBatch(names []string, datas [][]byte) []byte {
+	size := 0
+	for i := range names {
+		size += binary.MaxVarintLen32*2 + len(names[i]) + len(datas[i])
+	}
+
+	result := make([]byte, size)
+	n := 0
+	for i := range names {
+		n += binary.PutUvarint(result[n:], uint64(len(names[i])))
+		n += copy(result[n:], names[i])
+		n += binary.PutUvarint(result[n:], uint64(len(datas[i])))
+		n += copy(result[n:], datas[i])
+	}
+
+	return result[:n]
+}
+
+//UnpackBatch doc
+//@Summary unpack a batch container in order
+//@Param  batch container
+//@Param  agreement callback
+//@Return error
+func UnpackBatch(container []byte, f func(name string, data []byte) error) error {
+	for len(container) > 0 {
+		nameLength, n := binary.Uvarint(container)
+		if n <= 0 || nameLength == 0 || nameLength > uint64(len(container)-n) {
+			return code.ErrDataCorrupted
+		}
+		container = container[n:]
+		name := string(container[:nameLength])
+		container = container[nameLength:]
+
+		dataLength, n := binary.Uvarint(container)
+		if n <= 0 || dataLength > uint64(len(container)-n) {
+			return code.ErrDataCorrupted
+		}
+		container = container[n:]
+
+		if err := f(name, container[:dataLength]); err != nil {
+			return err
+		}
+		container = container[dataLength:]
+	}
+
+	return nil
+}
+
+//sendTo encode a message and send to client, queued when batch enabled
+func (slf *Server) sendTo(c *client, msg interface{}) error {
+	if slf._batchWindow <= 0 {
+		c._sendSync.Lock()
+		defer c._sendSync.Unlock()
+		return slf.send(c, msg)
+	}
+
+	size := 0
+	switch m := msg.(type) {
+	case *AgreEncoded:
+		size = len(m.AgreementData)
+	case proto.Message:
+		size = proto.Size(m)
+	}
+
+	if c.pushBatch(msg, size) < slf._batchLimit {
+		slf._batchSync.Lock()
+		slf._batchDirty[c.GetID()] = struct{}{}
+		slf._batchSync.Unlock()
+		return nil
+	}
+
+	return slf.flushBatch(c)
+}
+
+//send encode and send a message to client, the send lock is held by caller
+func (slf *Server) send(c *client, msg interface{}) error {
+	d, err := slf._delegate.AsyncEncode(c, msg)
+	if err != nil {
+		return err
+	}
+	return c.SendTo(d)
+}
+
+//flushBatch send queued messages of client as one batch frame
+func (slf *Server) flushBatch(c *client) error {
+	c._sendSync.Lock()
+	defer c._sendSync.Unlock()
+
+	msgs := c.takeBatch()
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var d []byte
+	var err error
+	if len(msgs) == 1 {
+		d, err = slf._delegate.AsyncEncode(c, msgs[0])
+	} else {
+		d, err = slf._delegate.AsyncEncodeBatch(c, msgs)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.SendTo(d)
+}
+
+func (slf *Server) asyncBatch([]interface{}) {
+	defer slf._listenWait.Done()
+	for {
+		if slf._ishutdown {
+			break
+		}
+
+		time.Sleep(time.Duration(slf._batchWindow) * time.Millisecond)
+
+		slf._batchSync.Lock()
+		dirty := slf._batchDirty
+		slf._batchDirty = make(map[uint64]struct{})
+		slf._batchSync.Unlock()
+
+		for h := range dirty {
+			c := slf._listenHandle.Grap(h)
+			if c == nil {
+				continue
+			}
+
+			if err := slf.flushBatch(c.(*client)); err != nil {
+				slf._listenHandle.LogError("client batch %s => %d %s", c.GetAddr(), c.GetSocket(), err.Error())
+			}
+			slf._listenHandle.Release(c)
+		}
+	}
+}
@@ -0,0 +1 @@
+protoc -I=. -I=%GOPATH%\src --gogoslick_out=. resume.proto error.proto auth.proto
\ No newline at end of file
@@ -0,0 +1,169 @@
+package gateway
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicNet/handler/encryption"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	//CipherRC4 rc4 stream cipher
+	CipherRC4 = 0
+	//CipherAESGCM aes-256-gcm
+	CipherAESGCM = 1
+	//CipherChaCha20 chacha20-poly1305
+	CipherChaCha20 = 2
+)
+
+const (
+	constNonceServer = 0x53
+	constNonceClient = 0x43
+	constKeyLabel    = "magicGame session key"
+)
+
+//IAEADEncryption doc
+//@Summary authenticated session encryptor, the frame header is sent
+//         in clear and authenticated as additional data
+//@Member Overhead tag length
+//@Member Seal encrypt and authenticate in place, returns sealed data
+//@Member Open decrypt and authenticate in place, returns plain data
+type IAEADEncryption interface {
+	encryption.INetEncryption
+	Overhead() int
+	Seal(header, plain []byte) []byte
+	Open(header, sealed []byte) ([]byte, error)
+}
+
+//NewSessionEncrypt doc
+//@Summary Create a session encryptor from dh64 secret
+//@Param  cipher mode
+//@Param  dh64 secret
+//@Param  is server side
+//@Return encryption.INetEncryption
+//@Return error
+func NewSessionEncrypt(mode int, secret []byte, server bool) (encryption.INetEncryption, error) {
+	if mode == CipherRC4 {
+		rc4 := &encryption.NetRC4Encrypt{}
+		if err := rc4.Cipher(secret); err != nil {
+			return nil, err
+		}
+		return rc4, nil
+	}
+
+	return NewAEADEncrypt(mode, secret, server)
+}
+
+//NewAEADEncrypt doc
+//@Summary Create an AEAD session encryptor from dh64 secret
+//@Param  cipher mode CipherAESGCM or CipherChaCha20
+//@Param  dh64 secret
+//@Param  is server side
+//@Return *AEADEncrypt
+//@Return error
+func NewAEADEncrypt(mode int, secret []byte, server bool) (*AEADEncrypt, error) {
+	h := sha256.New()
+	h.Write([]byte(constKeyLabel))
+	h.Write(secret)
+	key := h.Sum(nil)
+
+	var aead cipher.AEAD
+	var err error
+	switch mode {
+	case CipherAESGCM:
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err != nil {
+			return nil, err
+		}
+		aead, err = cipher.NewGCM(block)
+	case CipherChaCha20:
+		aead, err = chacha20poly1305.New(key)
+	default:
+		return nil, code.ErrCipherUnsupported
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := &AEADEncrypt{_aead: aead}
+	if server {
+		r._sendNonce[0] = constNonceServer
+		r._recvNonce[0] = constNonceClient
+	} else {
+		r._sendNonce[0] = constNonceClient
+		r._recvNonce[0] = constNonceServer
+	}
+
+	return r, nil
+}
+
+//AEADEncrypt doc
+//@Summary AEAD session encryptor, nonce is direction prefix and frame sequence
+type AEADEncrypt struct {
+	_aead      cipher.AEAD
+	_sendNonce [12]byte
+	_recvNonce [12]byte
+	_sendSeq   uint64
+	_recvSeq   uint64
+}
+
+//Encrypt doc
+//@Summary header is sent in clear
+func (slf *AEADEncrypt) Encrypt(dst, src []byte) {
+	copy(dst, src)
+}
+
+//Decode doc
+//@Summary header is received in clear
+func (slf *AEADEncrypt) Decode(dst, src []byte) {
+	copy(dst, src)
+}
+
+//Overhead doc
+//@Summary Returns authentication tag length
+func (slf *AEADEncrypt) Overhead() int {
+	return slf._aead.Overhead()
+}
+
+//Seal doc
+//@Summary encrypt plain in place, plain must have Overhead capacity
+//@Param  frame header
+//@Param  plain data
+//@Return sealed data
+func (slf *AEADEncrypt) Seal(header, plain []byte) []byte {
+	slf._sendSeq++
+	binary.BigEndian.PutUint64(slf._sendNonce[4:], slf._sendSeq)
+	return slf._aead.Seal(plain[:0], slf._sendNonce[:], plain, header)
+}
+
+//Open doc
+//@Summary decrypt sealed in place
+//@Param  frame header
+//@Param  sealed data
+//@Return plain data
+//@Return error
+func (slf *AEADEncrypt) Open(header, sealed []byte) ([]byte, error) {
+	if len(sealed) < slf._aead.Overhead() {
+		return nil, code.ErrDataTruncated
+	}
+
+	slf._recvSeq++
+	binary.BigEndian.PutUint64(slf._recvNonce[4:], slf._recvSeq)
+	plain, err := slf._aead.Open(sealed[:0], slf._recvNonce[:], sealed, header)
+	if err != nil {
+		return nil, code.ErrFrameAuthFailed
+	}
+
+	return plain, nil
+}
+
+//Destory doc
+//@Summary release encryptor
+func (slf *AEADEncrypt) Destory() {
+	slf._aead = nil
+}
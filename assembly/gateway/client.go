@@ -1,25 +1,66 @@
 package gateway
 
 import (
+	stdnet "net"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/yamakiller/magicNet/timer"
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
 
 	"github.com/yamakiller/magicNet/network"
 
 	"github.com/yamakiller/magicNet/engine/actor"
 	"github.com/yamakiller/magicNet/handler/encryption"
+	"github.com/yamakiller/magicNet/handler/net"
 	srvc "github.com/yamakiller/magicNet/handler/implement/client"
 )
 
+const (
+	//routed agreements of a client waiting for the route worker
+	constRouteQueue = 64
+)
+
+//gateway clients are the receive buffer of frame codec, fragments are always reassembled
+var _ IFragmentBuffer = (*client)(nil)
+
 type client struct {
 	srvc.NetSSrvCleint
-	_parent       *Server
-	_handle       uint64
-	_authLastTime int64
-	_auth         int64
-	_prvKey       uint64
-	_encrypt      encryption.INetEncryption
+	_parent     *Server
+	_handle     uint64
+	_state      int32
+	_stateTime  int64
+	_recvTime   int64
+	_auth       int64
+	_userID     uint64
+	_limiter    *rateLimiter
+	_ip         string
+	_prvKey     uint64
+	_pubKey     uint64
+	_version    uint16
+	_build      uint32
+	_encrypt    encryption.INetEncryption
+	_codec      FrameCodec
+	_fragment   FrameFragment
+	_sendSync   sync.Mutex
+	_batch      []interface{}
+	_batchSize  int
+	_batchSync  sync.Mutex
+	_conn       stdnet.Conn
+	_connSocket int32
+	_out        chan []byte
+	_closing    chan struct{}
+	_routes     []routeCall
+	_routing    bool
+	_routeSync  sync.Mutex
+}
+
+//routeCall routed agreement waiting for the route worker
+type routeCall struct {
+	_agree *DefaultAgreement
+	_req   *AgreMsg
 }
 
 //Initial doc
@@ -33,12 +74,7 @@ func (slf *client) Initial() {
 //@Summary Set handle/id
 //@Param uint64  handle/id
 func (slf *client) WithID(id uint64) {
-	slf._handle = id
-}
-
-func (slf *client) WithAuth(n int64) {
-	slf._auth = n
-	slf._authLastTime = int64(timer.Now())
+	atomic.StoreUint64(&slf._handle, id)
 }
 
 //WithEncrypt doc
@@ -47,6 +83,13 @@ func (slf *client) WithEncrypt(encrypt encryption.INetEncryption) {
 	slf._encrypt = encrypt
 }
 
+//isReady returns true when the session encryption is set, frames can be sent
+func (slf *client) isReady() bool {
+	slf._sendSync.Lock()
+	defer slf._sendSync.Unlock()
+	return slf._encrypt != nil
+}
+
 //WithPrvKey doc
 //@Summary Set private key
 func (slf *client) WithPrvKey(prvKey uint64) {
@@ -59,11 +102,109 @@ func (slf *client) GetPrvKey() uint64 {
 	return slf._prvKey
 }
 
+//WithPubKey doc
+//@Summary Set public key
+func (slf *client) WithPubKey(pubKey uint64) {
+	slf._pubKey = pubKey
+}
+
+//GetPubKey doc
+//@Summary Return public key
+func (slf *client) GetPubKey() uint64 {
+	return slf._pubKey
+}
+
+//GetVersion doc
+//@Summary Return negotiated protocol version
+func (slf *client) GetVersion() uint16 {
+	return slf._version
+}
+
+//GetBuild doc
+//@Summary Return client build
+func (slf *client) GetBuild() uint32 {
+	return slf._build
+}
+
+//GetSocket doc
+//@Summary Returns socket id, gateway owned connection is a negative id
+func (slf *client) GetSocket() int32 {
+	if slf._conn != nil {
+		return slf._connSocket
+	}
+	return slf.NetSSrvCleint.GetSocket()
+}
+
+//GetAddr doc
+//@Summary Returns remote address
+func (slf *client) GetAddr() string {
+	if slf._conn != nil {
+		return slf._conn.RemoteAddr().String()
+	}
+	return slf.NetSSrvCleint.GetAddr()
+}
+
+//SendTo doc
+//@Summary send data to client, gateway owned connection queues the data and
+//         is closed when the queue is full
+func (slf *client) SendTo(data []byte) error {
+	if slf._conn != nil {
+		select {
+		case slf._out <- data:
+			return nil
+		default:
+			//fail the blocked write now
+			slf._conn.SetWriteDeadline(time.Now())
+			notifyConn(slf._closing)
+			return code.ErrClientOutFull
+		}
+	}
+	return slf.NetSSrvCleint.SendTo(data)
+}
+
+//close close the client connection, gateway owned connection writes queued data
+//in linger time first
+func (slf *client) close() {
+	if slf._conn != nil {
+		slf._conn.SetWriteDeadline(time.Now().Add(constConnLinger * time.Millisecond))
+		notifyConn(slf._closing)
+		return
+	}
+	network.OperClose(slf.GetSocket())
+}
+
+//Fragment doc
+//@Summary Returns fragmented message reassembly state
+func (slf *client) Fragment() *FrameFragment {
+	return &slf._fragment
+}
+
+//pushBatch queue a message of batch, returns queued size
+func (slf *client) pushBatch(msg interface{}, size int) int {
+	slf._batchSync.Lock()
+	defer slf._batchSync.Unlock()
+
+	slf._batch = append(slf._batch, msg)
+	slf._batchSize += size
+	return slf._batchSize
+}
+
+//takeBatch returns and clear queued messages
+func (slf *client) takeBatch() []interface{} {
+	slf._batchSync.Lock()
+	defer slf._batchSync.Unlock()
+
+	msgs := slf._batch
+	slf._batch = nil
+	slf._batchSize = 0
+	return msgs
+}
+
 //GetID doc
 //@Summary Returns handle/id
 //@Return uint64
 func (slf *client) GetID() uint64 {
-	return slf._handle
+	return atomic.LoadUint64(&slf._handle)
 }
 
 //Encrypt doc
@@ -81,22 +222,42 @@ func (slf *client) Encrypt() encryption.INetEncryption {
 
 func (slf *client) onAgreement(context actor.Context, sender *actor.PID, message interface{}) {
 	req := message.(*AgreMsg)
-	m := slf._parent._delegate.getLocalCall(req.AgreementData)
-	if m == nil {
+	if state := slf.GetState(); state != StateUnauthenticated && state != StateAuthenticated {
+		slf.LogDebug("client %s => %d %s dropped, %s", slf.GetAddr(), slf.GetSocket(), req.Agreement.(string), state)
+		return
+	}
+
+	if slf._parent._authenticator != nil && slf._parent._authenticator.IsLogin(req.AgreementData) {
+		slf._parent.login(slf, req.AgreementData)
+		return
+	}
+
+	agree := slf._parent._delegate.getAgreement(req.AgreementData)
+	if agree == nil {
 		slf.LogError("local client %s => %d %s undefined", slf.GetAddr(), slf.GetSocket(), req.Agreement.(string))
 		return
 	}
 
+	if !slf._parent.isAllowed(slf, agree) {
+		slf._parent.rejectAuth(slf, req)
+		return
+	}
+
+	if agree.LocalMethod == nil {
+		slf.route(agree, req)
+		return
+	}
+
 	params := make([]reflect.Value, 2)
 	params[0] = reflect.ValueOf(slf.GetID())
 	params[1] = reflect.ValueOf(req.AgreementData)
-	rs := reflect.ValueOf(m).Call(params)
+	rs := reflect.ValueOf(agree.LocalMethod).Call(params)
 	numOut := len(rs)
 	if numOut > 0 {
 		if rs[numOut-1].IsValid() {
 			if e, ok := rs[numOut-1].Interface().(error); ok {
-				slf.LogError("local client %s => %d %s", slf.GetAddr(), slf.GetSocket(), e.Error)
-				network.OperClose(slf.GetSocket())
+				slf.LogError("local client %s => %d %s", slf.GetAddr(), slf.GetSocket(), e.Error())
+				slf.close()
 				return
 			}
 		}
@@ -105,28 +266,124 @@ func (slf *client) onAgreement(context actor.Context, sender *actor.PID, message
 			return
 		}
 
-		d, err := slf._parent._delegate.AsyncEncode(slf, rs[0].Interface())
-		if err != nil {
-			slf.LogError("client response %s => [%d] %s", slf.GetAddr(), slf.GetSocket(), err.Error)
+		if err := slf._parent.sendTo(slf, rs[0].Interface()); err != nil {
+			slf.LogError("response to client %s => %d %s", slf.GetAddr(), slf.GetSocket(), err.Error())
 			return
 		}
+	}
+}
 
-		if err = slf.SendTo(d); err != nil {
-			slf.LogError("response to client %s => %d %s", slf.GetAddr(), slf.GetSocket(), err.Error)
-			return
+//route queue a routed agreement, a worker of client calls routes in order so the client
+//actor is not blocked by remote calls. Agreements over the queue limit are answered by
+//AgreementError
+func (slf *client) route(agree *DefaultAgreement, req *AgreMsg) {
+	slf._routeSync.Lock()
+	if len(slf._routes) >= constRouteQueue {
+		slf._routeSync.Unlock()
+		slf.LogError("route client %s => %d %s busy", slf.GetAddr(), slf.GetSocket(), req.Agreement.(string))
+		rsp := &AgreementError{Agreement: req.Agreement.(string), Code: ErrorRouteBusy}
+		if err := slf._parent.sendTo(slf, rsp); err != nil {
+			slf.LogError("response to client %s => %d %s", slf.GetAddr(), slf.GetSocket(), err.Error())
+		}
+		return
+	}
+
+	slf._routes = append(slf._routes, routeCall{_agree: agree, _req: req})
+	if slf._routing {
+		slf._routeSync.Unlock()
+		return
+	}
+
+	//the worker keeps the client until the queue is empty
+	c := slf._parent._group.Grap(slf.GetID())
+	if c != slf {
+		//closed or taken over by a resumed connection
+		if c != nil {
+			slf._parent._group.Release(c)
 		}
+		slf._routes = nil
+		slf._routeSync.Unlock()
+		return
+	}
+	slf._routing = true
+	slf._routeSync.Unlock()
+
+	go slf.asyncRoute(c)
+}
+
+func (slf *client) asyncRoute(c net.INetClient) {
+	for {
+		slf._routeSync.Lock()
+		if len(slf._routes) == 0 {
+			slf._routing = false
+			slf._routes = nil
+			slf._routeSync.Unlock()
+			break
+		}
+
+		r := slf._routes[0]
+		slf._routes = slf._routes[1:]
+		slf._routeSync.Unlock()
+
+		slf.onRoute(r._agree, r._req)
+	}
+	slf._parent._group.Release(c)
+}
+
+//onRoute forward agreement to the route address, the response is sent back to client
+func (slf *client) onRoute(agree *DefaultAgreement, req *AgreMsg) {
+	var rsp proto.Message
+	if agree.Response != nil {
+		rsp = reflect.New(reflect.TypeOf(agree.Response).Elem()).Interface().(proto.Message)
+	}
+
+	if err := slf._parent._rss.Call(agree.Addr, agree.RemoteMethod, req.AgreementData.(proto.Message), rsp); err != nil {
+		slf.LogError("route client %s => %d %s %s", slf.GetAddr(), slf.GetSocket(), req.Agreement.(string), err.Error())
+		return
+	}
+
+	if rsp == nil {
+		return
+	}
+
+	if err := slf._parent.sendTo(slf, rsp); err != nil {
+		slf.LogError("response to client %s => %d %s", slf.GetAddr(), slf.GetSocket(), err.Error())
 	}
 }
 
 func (slf *client) Shutdown() {
+	if slf._parent != nil {
+		slf._parent.transit(slf, StateClosed)
+		if slf._ip != "" {
+			slf._parent._admission.release(slf._ip)
+		}
+	}
+
 	slf.NetSSrvCleint.Shutdown()
 	if slf._encrypt != nil {
 		slf._encrypt.Destory()
 		slf._encrypt = nil
 	}
 
-	slf._auth = 0
-	slf._handle = 0
+	atomic.StoreInt64(&slf._auth, 0)
+	atomic.StoreUint64(&slf._userID, 0)
+	slf._limiter = nil
+	slf._ip = ""
+	slf._fragment.Reset()
+	slf.takeBatch()
+	slf._pubKey = 0
+	slf._version = 0
+	slf._build = 0
+	slf._codec = nil
+	atomic.StoreUint64(&slf._handle, 0)
 	slf._parent = nil
-	slf._authLastTime = 0
+	slf._state = int32(StateClosed)
+	slf._stateTime = 0
+	slf._recvTime = 0
+	slf._conn = nil
+	slf._connSocket = 0
+	slf._out = nil
+	slf._closing = nil
+	slf._routes = nil
+	slf._routing = false
 }
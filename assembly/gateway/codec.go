@@ -0,0 +1,529 @@
+package gateway
+
+import (
+	"encoding/binary"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicNet/handler/encryption"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+const (
+	constHeadSize = 32
+	//
+	constHeadByte = 4
+	//data flag size
+	constDataFlagSize = 3
+	//data flag mask
+	constDataFlagMask = 0x7
+	//data flag shift
+	constDataFlagShift = 29
+	//
+	constDataLengthSize = 21
+	//data length start pos bit
+	constDataLengthStart = constDataFlagSize
+	//data length mask
+	constDataLengthMask = 0x1FFFFF
+	//data length shift
+	constDataLengthShift = 8
+	//data name length size
+	constDataNameLengthSize = 8
+	//data name length start pos bit
+	constDataNameLengthStart = constDataLengthStart + constDataLengthSize
+	//data name length mask
+	constDataNameLengthMask = 0xFF
+	//data name length shift
+	constDataNameLengthShift = 0
+	//data length mask of protocol version 1, the header has no flag
+	constLegacyLengthMask = 0xFFFFFF
+)
+
+//MaxFrameData maximum data length of a frame, the 21 bit data length of protocol version 2
+//is 2MB. Larger messages are sent in fragments, see DefaultFrameCodec.MaxMessageSize
+const MaxFrameData = constDataLengthMask
+
+//MaxLegacyFrameData maximum data length of a frame, the 24 bit data length of protocol version 1
+//is 16MB. Messages are not fragmented
+const MaxLegacyFrameData = constLegacyLengthMask
+
+const (
+	//data is compressed
+	constFlagCompress = 0x1
+	//more fragments of the message follow
+	constFlagFragment = 0x2
+	//data is a batch container
+	constFlagBatch = 0x4
+)
+
+//FrameCodec doc
+//@Summary gateway frame codec interface, empty agreement name is a batch container(see PackBatch)
+//@Member Decode read a frame from the receive buffer, returns agreement name and data
+//@Member Encode build a frame from agreement name and data
+type FrameCodec interface {
+	Decode(encryption.INetEncryption, net.INetReceiveBuffer) (string, []byte, error)
+	Encode(encryption.INetEncryption, string, []byte) ([]byte, error)
+}
+
+//IFragmentBuffer doc
+//@Summary receive buffer able to hold a message being reassembled,
+//         stream cipher frames received partially require it to keep the decrypted header
+//@Member Fragment Returns reassembly state
+type IFragmentBuffer interface {
+	net.INetReceiveBuffer
+	Fragment() *FrameFragment
+}
+
+//FrameFragment doc
+//@Summary fragmented message reassembly state and header of the partial frame
+type FrameFragment struct {
+	_active bool
+	_flags  int
+	_name   string
+	_data   []byte
+	_headed bool
+	_head   uint32
+}
+
+//Reset doc
+//@Summary drop the message being reassembled
+func (slf *FrameFragment) Reset() {
+	slf._active = false
+	slf._flags = 0
+	slf._name = ""
+	slf._data = nil
+	slf._headed = false
+	slf._head = 0
+}
+
+//DefaultFrameCodec doc
+//@Summary default frame codec, 3 bit flag, 21 bit data length and 8 bit data name length.
+//         Frame data is at most MaxFrameData, encoding a larger message without fragment
+//         returns code.ErrFrameOverflow
+//@Member compressor, nil is not compress
+//@Member data length greater than threshold will be compressed
+//@Member fragment data length, 0 is maximum frame data length
+//@Member maximum message length of reassembled, 0 is not fragment
+//@Member frame layout of protocol version 1, 24 bit data length and 8 bit data name length
+//        without flag. Frame data is at most MaxLegacyFrameData, compressor, fragment and
+//        batch container are not used
+type DefaultFrameCodec struct {
+	Compressor     Compressor
+	Threshold      int
+	FragmentSize   int
+	MaxMessageSize int
+	Legacy         bool
+	_names         nameCache
+}
+
+//IMessageEncoder doc
+//@Summary optional FrameCodec extension, encode a message without intermediate buffer
+//@Member EncodeMessage build a frame from agreement name and message
+type IMessageEncoder interface {
+	EncodeMessage(encryption.INetEncryption, string, proto.Message) ([]byte, error)
+}
+
+//sizedMarshaler gogo generated message
+type sizedMarshaler interface {
+	Size() int
+	MarshalToSizedBuffer([]byte) (int, error)
+}
+
+//Decode doc
+//@Summary decode a frame, fragments are reassembled when the receive buffer is IFragmentBuffer
+//@Param  encryptor, nil is not decrypt
+//@Param  receive buffer
+//@Return agreement name
+//@Return agreement data
+//@Return error
+func (slf *DefaultFrameCodec) Decode(encrypt encryption.INetEncryption, bf net.INetReceiveBuffer) (string, []byte, error) {
+	for {
+		flags, name, data, err := decoder(encrypt, bf, &slf._names, slf.Legacy)
+		if err != nil {
+			return "", nil, err
+		}
+
+		var fr *FrameFragment
+		if fb, ok := bf.(IFragmentBuffer); ok {
+			fr = fb.Fragment()
+		}
+
+		if fr == nil || !fr._active {
+			if len(name) == 0 && (flags&constFlagBatch) == 0 {
+				return "", nil, code.ErrDataNameEmpty
+			}
+
+			if len(name) != 0 && (flags&constFlagBatch) != 0 {
+				return "", nil, code.ErrDataCorrupted
+			}
+		}
+
+		if (flags&constFlagFragment) == 0 && (fr == nil || !fr._active) {
+			data, err = slf.decompress(flags, data, slf.maxMessageSize())
+			if err != nil {
+				return "", nil, err
+			}
+			return name, data, nil
+		}
+
+		if fr == nil || slf.MaxMessageSize <= 0 {
+			return "", nil, code.ErrFragmentUnsupported
+		}
+
+		if !fr._active {
+			fr._active = true
+			fr._flags = flags
+			fr._name = name
+		} else if len(name) != 0 {
+			fr.Reset()
+			return "", nil, code.ErrDataCorrupted
+		}
+
+		if len(fr._data)+len(data) > slf.MaxMessageSize {
+			fr.Reset()
+			return "", nil, code.ErrDataOverflow
+		}
+		fr._data = append(fr._data, data...)
+
+		if (flags & constFlagFragment) != 0 {
+			//wait next fragment
+			continue
+		}
+
+		flags, name, data = fr._flags, fr._name, fr._data
+		fr.Reset()
+
+		data, err = slf.decompress(flags, data, slf.MaxMessageSize)
+		if err != nil {
+			return "", nil, err
+		}
+		return name, data, nil
+	}
+}
+
+//Encode doc
+//@Summary encode a frame, data larger than fragment size is split into multiple frames
+//@Param  encryptor, nil is not encrypt
+//@Param  agreement name
+//@Param  agreement data
+//@Return frame data
+//@Return error
+func (slf *DefaultFrameCodec) Encode(encrypt encryption.INetEncryption, dataName string, data []byte) ([]byte, error) {
+	if len(dataName) > constDataNameLengthMask {
+		return nil, code.ErrDataNameOverflow
+	}
+
+	if len(data) > slf.maxMessageSize() {
+		if slf.MaxMessageSize > 0 && (!slf.Legacy || slf.MaxMessageSize < constLegacyLengthMask) {
+			return nil, code.ErrDataOverflow
+		}
+		return nil, code.ErrFrameOverflow
+	}
+
+	flags := 0
+	if len(dataName) == 0 {
+		if slf.Legacy {
+			return nil, code.ErrDataNameEmpty
+		}
+		flags |= constFlagBatch
+	}
+
+	if slf.Compressor != nil && !slf.Legacy && len(data) > slf.Threshold {
+		bp := getBuffer(0)
+		defer putBuffer(bp)
+
+		tmpData, err := slf.Compressor.Compress(*bp, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tmpData) < len(data) {
+			data = tmpData
+			flags |= constFlagCompress
+		}
+	}
+
+	fragmentSize := slf.fragmentSize(encrypt)
+	if len(data) <= fragmentSize {
+		return encoder(encrypt, slf.Legacy, flags, dataName, data), nil
+	}
+
+	if slf.MaxMessageSize <= 0 || slf.Legacy {
+		return nil, code.ErrFrameOverflow
+	}
+
+	overhead := getOverhead(encrypt)
+	fragments := (len(data) + fragmentSize - 1) / fragmentSize
+	result := make([]byte, fragments*(constHeadByte+overhead)+len(dataName)+len(data))
+	offset := 0
+	for len(data) > 0 {
+		n := len(data)
+		fragmentFlags := flags
+		if n > fragmentSize {
+			n = fragmentSize
+			fragmentFlags |= constFlagFragment
+		}
+
+		offset += encodeTo(result[offset:], encrypt, false, fragmentFlags, dataName, data[:n])
+		dataName = ""
+		data = data[n:]
+	}
+
+	return result, nil
+}
+
+//EncodeMessage doc
+//@Summary encode a message, gogo generated message is marshaled into the frame directly
+//@Param  encryptor, nil is not encrypt
+//@Param  agreement name
+//@Param  message
+//@Return frame data
+//@Return error
+func (slf *DefaultFrameCodec) EncodeMessage(encrypt encryption.INetEncryption, dataName string, msg proto.Message) ([]byte, error) {
+	m, ok := msg.(sizedMarshaler)
+	if !ok {
+		d, err := proto.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		return slf.Encode(encrypt, dataName, d)
+	}
+
+	size := m.Size()
+	if (slf.Compressor != nil && !slf.Legacy && size > slf.Threshold) || size > slf.fragmentSize(encrypt) {
+		//compress or fragment, marshal to pooled buffer
+		bp := getBuffer(size)
+		defer putBuffer(bp)
+
+		n, err := m.MarshalToSizedBuffer(*bp)
+		if err != nil {
+			return nil, err
+		}
+		return slf.Encode(encrypt, dataName, (*bp)[size-n:])
+	}
+
+	if len(dataName) > constDataNameLengthMask {
+		return nil, code.ErrDataNameOverflow
+	}
+
+	flags := 0
+	if len(dataName) == 0 {
+		if slf.Legacy {
+			return nil, code.ErrDataNameEmpty
+		}
+		flags |= constFlagBatch
+	}
+
+	overhead := getOverhead(encrypt)
+	result := make([]byte, constHeadByte+len(dataName)+size+overhead)
+	offset := putFrameHead(result, slf.Legacy, flags, dataName, size+overhead)
+	n, err := m.MarshalToSizedBuffer(result[offset : offset+size])
+	if err != nil {
+		return nil, err
+	}
+
+	if n != size {
+		return nil, code.ErrDataCorrupted
+	}
+
+	sealFrame(encrypt, result, len(dataName), size)
+	return result, nil
+}
+
+func (slf *DefaultFrameCodec) fragmentSize(encrypt encryption.INetEncryption) int {
+	if slf.Legacy {
+		return constLegacyLengthMask - getOverhead(encrypt)
+	}
+
+	fragmentSize := constDataLengthMask - getOverhead(encrypt)
+	if slf.FragmentSize > 0 && slf.FragmentSize < fragmentSize {
+		fragmentSize = slf.FragmentSize
+	}
+	return fragmentSize
+}
+
+//maxMessageSize returns maximum message length, it is a frame without fragment
+func (slf *DefaultFrameCodec) maxMessageSize() int {
+	if slf.Legacy && (slf.MaxMessageSize <= 0 || slf.MaxMessageSize > constLegacyLengthMask) {
+		return constLegacyLengthMask
+	}
+
+	if slf.MaxMessageSize > 0 {
+		return slf.MaxMessageSize
+	}
+	return constDataLengthMask
+}
+
+func (slf *DefaultFrameCodec) decompress(flags int, data []byte, limit int) ([]byte, error) {
+	if (flags & constFlagCompress) == 0 {
+		return data, nil
+	}
+
+	if slf.Compressor == nil {
+		return nil, code.ErrCompressUnsupported
+	}
+
+	return slf.Compressor.Decompress(data, limit)
+}
+
+func getOverhead(encrypt encryption.INetEncryption) int {
+	if aead, ok := encrypt.(IAEADEncryption); ok {
+		return aead.Overhead()
+	}
+	return 0
+}
+
+func getDataFlag(d uint32, legacy bool) int {
+	if legacy {
+		return 0
+	}
+	return int((d >> constDataFlagShift) & constDataFlagMask)
+}
+
+func getDataLength(d uint32, legacy bool) int {
+	if legacy {
+		return int((d >> constDataLengthShift) & constLegacyLengthMask)
+	}
+	return int((d >> constDataLengthShift) & constDataLengthMask)
+}
+
+func getDataNameLength(d uint32) int {
+	return int(d & constDataNameLengthMask)
+}
+
+func decoder(encrypt encryption.INetEncryption, bf net.INetReceiveBuffer, names *nameCache, legacy bool) (int, string, []byte, error) {
+
+	/***************************************************************************|
+	|   3 Bit   |   21 Bit      |    8 Bit	  |     N Bit    |    （N） Bit      |
+	|-----------|-------------  |-------------|--------------|------------------|
+	| Data Flag |  Data Length  |  Data Name  |  Data Name   |      Data        |
+	|			|			    |	Length    |				 |					|
+	****************************************************************************/
+
+	/***************************************************************************|
+	|  protocol version 1                                                        |
+	|      24 Bit   |    8 Bit	  |     N Bit    |    （N） Bit      |
+	|-------------  |-------------|--------------|------------------|
+	|  Data Length  |  Data Name  |  Data Name   |      Data        |
+	|			    |	Length    |				 |					|
+	****************************************************************************/
+
+	if bf.GetBufferLen() < constHeadByte {
+		return 0, "", nil, net.ErrAnalysisProceed
+	}
+
+	hp := headPool.Get().(*[constHeadByte]byte)
+	defer headPool.Put(hp)
+
+	var fr *FrameFragment
+	if fb, ok := bf.(IFragmentBuffer); ok {
+		fr = fb.Fragment()
+	}
+
+	tmpHead := hp[:]
+	var header uint32
+	if fr != nil && fr._headed {
+		//stream cipher state has moved past the header
+		header = fr._head
+		binary.BigEndian.PutUint32(tmpHead, header)
+	} else {
+		copy(tmpHead, bf.GetBufferBytes()[:constHeadByte])
+		if encrypt != nil {
+			encrypt.Decode(tmpHead, tmpHead)
+		}
+		header = binary.BigEndian.Uint32(tmpHead)
+	}
+
+	tmpDataFlag := getDataFlag(header, legacy)
+	tmpDataLength := getDataLength(header, legacy)
+	tmpDataNameLength := getDataNameLength(header)
+
+	if (constHeadByte + tmpDataLength + tmpDataNameLength) > (bf.GetBufferCap() << 1) {
+		return 0, "", nil, code.ErrDataOverflow
+	}
+
+	if tmpDataLength < getOverhead(encrypt) {
+		return 0, "", nil, code.ErrDataTruncated
+	}
+
+	if (tmpDataLength + tmpDataNameLength + constHeadByte) > bf.GetBufferLen() {
+		if fr != nil && encrypt != nil {
+			fr._head = header
+			fr._headed = true
+		}
+		return 0, "", nil, net.ErrAnalysisProceed
+	}
+
+	if fr != nil {
+		fr._headed = false
+	}
+
+	bf.TrunBuffer(constHeadByte)
+	tmpByte := bf.ReadBuffer(tmpDataNameLength + tmpDataLength)
+	if aead, ok := encrypt.(IAEADEncryption); ok {
+		var err error
+		if tmpByte, err = aead.Open(tmpHead, tmpByte); err != nil {
+			return 0, "", nil, err
+		}
+
+		if len(tmpByte) < tmpDataNameLength {
+			return 0, "", nil, code.ErrDataCorrupted
+		}
+	} else if encrypt != nil {
+		encrypt.Decode(tmpByte, tmpByte)
+	}
+
+	var name string
+	if names != nil {
+		name = names.intern(tmpByte[:tmpDataNameLength])
+	} else {
+		name = string(tmpByte[:tmpDataNameLength])
+	}
+	data := tmpByte[tmpDataNameLength:]
+
+	return tmpDataFlag, name, data, nil
+}
+
+func encoder(encrypt encryption.INetEncryption, legacy bool, flags int, dataName string, data []byte) []byte {
+	result := make([]byte, constHeadByte+len(dataName)+len(data)+getOverhead(encrypt))
+	encodeTo(result, encrypt, legacy, flags, dataName, data)
+	return result
+}
+
+//encodeTo write a frame to dst, returns frame length
+func encodeTo(dst []byte, encrypt encryption.INetEncryption, legacy bool, flags int, dataName string, data []byte) int {
+	overhead := getOverhead(encrypt)
+	offset := putFrameHead(dst, legacy, flags, dataName, len(data)+overhead)
+	copy(dst[offset:], data)
+	sealFrame(encrypt, dst, len(dataName), len(data))
+	return offset + len(data) + overhead
+}
+
+//putFrameHead write frame header and agreement name, returns data offset.
+//Legacy header of protocol version 1 has no flag
+func putFrameHead(dst []byte, legacy bool, flags int, dataName string, dataLength int) int {
+	dataNameLength := len(dataName)
+
+	var header uint32
+	if legacy {
+		header = uint32(((dataLength & constLegacyLengthMask) << constDataLengthShift))
+	} else {
+		header = uint32((flags & constDataFlagMask) << constDataFlagShift)
+		header = (header | uint32(((dataLength & constDataLengthMask) << constDataLengthShift)))
+	}
+	header = (header | uint32(dataNameLength&constDataNameLengthMask))
+
+	binary.BigEndian.PutUint32(dst, header)
+	copy(dst[constHeadByte:], dataName)
+	return constHeadByte + dataNameLength
+}
+
+//sealFrame encrypt a frame in place
+func sealFrame(encrypt encryption.INetEncryption, frame []byte, dataNameLength, dataLength int) {
+	if aead, ok := encrypt.(IAEADEncryption); ok {
+		aead.Seal(frame[:constHeadByte], frame[constHeadByte:constHeadByte+dataNameLength+dataLength])
+	} else if encrypt != nil {
+		frame = frame[:constHeadByte+dataNameLength+dataLength]
+		encrypt.Encrypt(frame, frame)
+	}
+}
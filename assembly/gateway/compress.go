@@ -0,0 +1,220 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+const (
+	//CompressNone not compress
+	CompressNone = 0
+	//CompressSnappy snappy compress
+	CompressSnappy = 1
+	//CompressLZ4 lz4 block compress
+	CompressLZ4 = 2
+	//CompressZstd zstd compress
+	CompressZstd = 3
+)
+
+//Compressor doc
+//@Summary frame data compressor interface
+//@Member ID compressor id
+//@Member Compress compress data, dst is a reusable buffer and the result may share it
+//@Member Decompress decompress data, result length cannot exceed the limit
+type Compressor interface {
+	ID() int
+	Compress(dst, src []byte) ([]byte, error)
+	Decompress(src []byte, limit int) ([]byte, error)
+}
+
+//SnappyCompressor doc
+//@Summary snappy compressor
+type SnappyCompressor struct {
+}
+
+//ID doc
+//@Summary Returns compressor id
+func (slf *SnappyCompressor) ID() int {
+	return CompressSnappy
+}
+
+//Compress doc
+//@Summary snappy compress data
+func (slf *SnappyCompressor) Compress(dst, src []byte) ([]byte, error) {
+	if n := snappy.MaxEncodedLen(len(src)); n > 0 && cap(dst) >= n {
+		dst = dst[:n]
+	}
+	return snappy.Encode(dst, src), nil
+}
+
+//Decompress doc
+//@Summary snappy decompress data
+func (slf *SnappyCompressor) Decompress(src []byte, limit int) ([]byte, error) {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, code.ErrDataCorrupted
+	}
+
+	if n > limit {
+		return nil, code.ErrDataOverflow
+	}
+
+	dst, err := snappy.Decode(nil, src)
+	if err != nil {
+		return nil, code.ErrDataCorrupted
+	}
+	return dst, nil
+}
+
+//LZ4Compressor doc
+//@Summary lz4 block compressor, the block is prefixed with 4 byte source length
+type LZ4Compressor struct {
+}
+
+//ID doc
+//@Summary Returns compressor id
+func (slf *LZ4Compressor) ID() int {
+	return CompressLZ4
+}
+
+//Compress doc
+//@Summary lz4 compress data
+func (slf *LZ4Compressor) Compress(dst, src []byte) ([]byte, error) {
+	n := 4 + lz4.CompressBlockBound(len(src))
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	binary.BigEndian.PutUint32(dst, uint32(len(src)))
+	n, err := lz4.CompressBlock(src, dst[4:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		//incompressible, the codec will send source data
+		return src, nil
+	}
+
+	return dst[:4+n], nil
+}
+
+//Decompress doc
+//@Summary lz4 decompress data
+func (slf *LZ4Compressor) Decompress(src []byte, limit int) ([]byte, error) {
+	if len(src) < 4 {
+		return nil, code.ErrDataCorrupted
+	}
+
+	n := int(binary.BigEndian.Uint32(src))
+	if n > limit {
+		return nil, code.ErrDataOverflow
+	}
+
+	dst := make([]byte, n)
+	m, err := lz4.UncompressBlock(src[4:], dst)
+	if err != nil {
+		return nil, code.ErrDataCorrupted
+	}
+
+	if m != n {
+		return nil, code.ErrDataCorrupted
+	}
+
+	return dst, nil
+}
+
+//NewZstdCompressor doc
+//@Summary Create a zstd compressor
+//@Param  encoder level
+//@Return *ZstdCompressor
+//@Return error
+func NewZstdCompressor(level zstd.EncoderLevel) (*ZstdCompressor, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ZstdCompressor{_enc: enc}, nil
+}
+
+//ZstdCompressor doc
+//@Summary zstd compressor, decoded memory is capped by the limit of Decompress
+type ZstdCompressor struct {
+	_enc  *zstd.Encoder
+	_decs sync.Map
+}
+
+//ID doc
+//@Summary Returns compressor id
+func (slf *ZstdCompressor) ID() int {
+	return CompressZstd
+}
+
+//Compress doc
+//@Summary zstd compress data
+func (slf *ZstdCompressor) Compress(dst, src []byte) ([]byte, error) {
+	return slf._enc.EncodeAll(src, dst[:0]), nil
+}
+
+//Decompress doc
+//@Summary zstd decompress data
+func (slf *ZstdCompressor) Decompress(src []byte, limit int) ([]byte, error) {
+	var h zstd.Header
+	if err := h.Decode(src); err != nil {
+		return nil, code.ErrDataCorrupted
+	}
+
+	if h.HasFCS && h.FrameContentSize > uint64(limit) {
+		return nil, code.ErrDataOverflow
+	}
+
+	dec, err := slf.getDecoder(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := dec.DecodeAll(src, nil)
+	if err != nil {
+		return nil, code.ErrDataCorrupted
+	}
+
+	if len(dst) > limit {
+		return nil, code.ErrDataOverflow
+	}
+
+	return dst, nil
+}
+
+//getDecoder returns the decoder of a decoded memory limit, codecs sharing the compressor may use different limits
+func (slf *ZstdCompressor) getDecoder(limit int) (*zstd.Decoder, error) {
+	if dec, ok := slf._decs.Load(limit); ok {
+		return dec.(*zstd.Decoder), nil
+	}
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(limit)))
+	if err != nil {
+		return nil, err
+	}
+
+	if v, loaded := slf._decs.LoadOrStore(limit, dec); loaded {
+		dec.Close()
+		return v.(*zstd.Decoder), nil
+	}
+	return dec, nil
+}
+
+//Close doc
+//@Summary release zstd encoder/decoder
+func (slf *ZstdCompressor) Close() {
+	slf._enc.Close()
+	slf._decs.Range(func(k, v interface{}) bool {
+		v.(*zstd.Decoder).Close()
+		return true
+	})
+}
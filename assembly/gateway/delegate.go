@@ -2,144 +2,85 @@ package gateway
 
 import (
 	"encoding/binary"
-	"fmt"
 	"reflect"
 
+	"github.com/yamakiller/magicGame/assembly/code"
 	"github.com/yamakiller/magicLibs/encryption/dh64"
 	"github.com/yamakiller/magicNet/handler/encryption"
 
 	"github.com/gogo/protobuf/proto"
-	"github.com/yamakiller/magicGame/assembly/code"
 	"github.com/yamakiller/magicNet/handler/net"
 )
 
-const (
-	constHeadSize = 32
-	//
-	constHeadByte = 4
-	//
-	constDataLengthSize = 24
-	//data length start pos bit
-	constDataLengthStart = 0
-	//data length mask
-	constDataLengthMask = 0xFFFFFF
-	//data length shift
-	constDataLengthShift = 8
-	//data name length size
-	constDataNameLengthSize = 8
-	//data name length start pos bit
-	constDataNameLengthStart = constDataLengthStart + constDataLengthSize
-	//data name length mask
-	constDataNameLengthMask = 0xFF
-	//data name length shift
-	constDataNameLengthShift = 0
-)
-
-func getDataLength(d uint32) int {
-	return int((d >> constDataLengthShift) & constDataLengthMask)
-}
-
-func getDataNameLength(d uint32) int {
-	return int(d & constDataNameLengthMask)
-}
-
-func decoder(encrypt encryption.INetEncryption, bf net.INetReceiveBuffer) (string, []byte, error) {
-
-	/***************************************************************|
-	|      24 Bit   |    8 Bit	  |     N Bit    |    （N） Bit      |
-	|-------------  |-------------|--------------|------------------|
-	|  Data Length  |  Data Name  |  Data Name   |      Data        |
-	|			    |	Length    |				 |					|
-	****************************************************************/
-
-	if bf.GetBufferLen() < constHeadByte {
-		return "", nil, net.ErrAnalysisProceed
-	}
-
-	tmpByte := make([]byte, 4)
-	copy(tmpByte, bf.GetBufferBytes()[:constHeadByte])
-	if encrypt != nil {
-		encrypt.Decode(tmpByte, tmpByte)
-	}
-	header := binary.BigEndian.Uint32(tmpByte)
-	tmpDataLength := getDataLength(header)
-	tmpDataNameLength := getDataNameLength(header)
-
-	if (tmpDataLength + tmpDataNameLength + constHeadByte) > bf.GetBufferLen() {
-		return "", nil, net.ErrAnalysisProceed
-	}
-
-	if (constHeadByte + tmpDataLength + tmpDataNameLength) > (bf.GetBufferCap() << 1) {
-		return "", nil, code.ErrDataOverflow
-	}
-
-	bf.TrunBuffer(constHeadByte)
-	tmpByte = bf.ReadBuffer(tmpDataNameLength + tmpDataLength)
-	if encrypt != nil {
-		encrypt.Decode(tmpByte, tmpByte)
-	}
-
-	name := string(tmpByte[:tmpDataNameLength])
-	data := tmpByte[tmpDataNameLength:]
-
-	return name, data, nil
-}
-
-func encoder(encrypt encryption.INetEncryption, dataName string, data []byte) []byte {
-	dataNameLength := len([]byte(dataName))
-	dataLength := len(data)
-
-	header := uint32(((dataLength & constDataLengthMask) << constDataLengthShift))
-	header = (header | uint32(dataNameLength&constDataNameLengthMask))
-
-	result := make([]byte, constHeadByte+dataNameLength+dataLength)
-	binary.BigEndian.PutUint32(result, header)
-	copy(result[constHeadByte:], []byte(dataName))
-	copy(result[constHeadByte+dataNameLength:], data)
-
-	if encrypt != nil {
-		encrypt.Encrypt(result, result)
-	}
-
-	return result
-}
-
 //DefaultAgreement doc
-//@Summary default agreement method
+//@Summary default agreement method, the agreement runs local method when it is set,
+//         otherwise it is forwarded to the route address
 //@Member  route address
-//@Member  local method
-//@Member  remove method
-//@Member  is auth
+//@Member  local method, func(handle uint64, request) (response, error)
+//@Member  remote method
+//@Member  remote response prototype, nil is no response
+//@Member  is auth, client must be authenticated at any level above 0
+//@Member  minimum auth level of authenticated client, it implies auth, see Server.WithCliAuth
 type DefaultAgreement struct {
-	Addr        string
-	LocalMethod interface{}
-	Auth        bool
+	Addr         string
+	LocalMethod  interface{}
+	RemoteMethod string
+	Response     proto.Message
+	Auth         bool
+	MinAuth      int64
 }
 
+const (
+	//NameFrame frame carries the message full name
+	NameFrame = 0
+	//IDFrame frame carries the message id of the Registry
+	IDFrame = 1
+)
+
+var defaultCodec FrameCodec = &DefaultFrameCodec{}
+
 //DefaultDelegate doc
 //@Summary default gateserver delegate instance
 //@Member  map  method
+//@Member  frame mode NameFrame or IDFrame
+//@Member  message id registry, IDFrame mode required, gateway.New fails without it
 type DefaultDelegate struct {
-	KeyExc  *dh64.KeyExchange
-	Encrypt bool
-	Maps    map[interface{}]interface{}
+	KeyExc    *dh64.KeyExchange
+	Encrypt   bool
+	Maps      map[interface{}]interface{}
+	FrameMode int
+	Registry  *MessageRegistry
 }
 
 //PutLocalCall doc
-//@Summary register agreement
+//@Summary register agreement of local method
 //@Param agreement
-//@Param route address
 //@Param local method
-//@Param remote method
-//@Param is need auth
-func (slf *DefaultDelegate) PutLocalCall(param interface{}, localMethod interface{}) {
+//@Return error, code.ErrAgreementUndefined when the local method is nil
+func (slf *DefaultDelegate) PutLocalCall(param interface{}, localMethod interface{}) error {
+	return slf.PutAgreement(param, &DefaultAgreement{LocalMethod: localMethod})
+}
 
-	slf.Maps[reflect.TypeOf(param)] = localMethod
+//PutAgreement doc
+//@Summary register agreement
+//@Param agreement
+//@Param agreement method
+//@Return error, code.ErrAgreementUndefined when the agreement has neither local method nor route address
+func (slf *DefaultDelegate) PutAgreement(param interface{}, agree *DefaultAgreement) error {
+	if agree == nil || (agree.LocalMethod == nil && agree.Addr == "") {
+		return code.ErrAgreementUndefined
+	}
+
+	if slf.Maps == nil {
+		slf.Maps = make(map[interface{}]interface{})
+	}
+	slf.Maps[reflect.TypeOf(param)] = agree
+	return nil
 }
 
-func (slf *DefaultDelegate) getLocalCall(param interface{}) interface{} {
+func (slf *DefaultDelegate) getAgreement(param interface{}) *DefaultAgreement {
 	if v, ok := slf.Maps[reflect.TypeOf(param)]; ok {
-		return v
+		return v.(*DefaultAgreement)
 	}
 
 	return nil
@@ -150,13 +91,10 @@ func (slf *DefaultDelegate) getLocalCall(param interface{}) interface{} {
 //@Param  client
 //@Return error
 func (slf *DefaultDelegate) AsyncAccept(c net.INetClient) error {
+	//client speaks first, waiting for the client hello
 	privateKey, publicKey := slf.KeyExc.KeyPair()
 	c.(*client).WithPrvKey(privateKey)
-	x := make([]byte, 8)
-	binary.BigEndian.PutUint64(x, publicKey)
-	if err := c.SendTo(x); err != nil {
-		return err
-	}
+	c.(*client).WithPubKey(publicKey)
 	return nil
 }
 
@@ -168,6 +106,14 @@ func (slf *DefaultDelegate) AsyncClosed(handle uint64) error {
 	return nil
 }
 
+//AsyncState doc
+//@Summary client session state changed event
+//@Param client
+//@Param previous state
+//@Param current state
+func (slf *DefaultDelegate) AsyncState(c net.INetClient, from, to SessionState) {
+}
+
 //AsyncDecode doc
 //@Summary network data decode method
 //@Param   client
@@ -176,40 +122,96 @@ func (slf *DefaultDelegate) AsyncClosed(handle uint64) error {
 func (slf *DefaultDelegate) AsyncDecode(c net.INetClient) (*AgreMsg, error) {
 	gwClient := c.(*client)
 	if gwClient.Encrypt() == nil {
-		if c.GetBufferLen() < 8 {
-			return nil, net.ErrAnalysisProceed
+		if err := slf.handshake(gwClient); err != nil {
+			return nil, err
 		}
+	}
 
-		publicKey := binary.BigEndian.Uint64(c.ReadBuffer(8))
-		secret := slf.KeyExc.Secret(gwClient.GetPrvKey(), publicKey)
-		rc4 := &encryption.NetRC4Encrypt{}
-		secretByte := make([]byte, 8)
-		binary.BigEndian.PutUint64(secretByte, secret)
-		if err := rc4.Cipher(secretByte); err != nil {
+	name, data, err := slf.getCodec(gwClient).Decode(slf.getEncrypt(gwClient), c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(name) == 0 {
+		batch := AgreBatch{}
+		err = UnpackBatch(data, func(name string, data []byte) error {
+			agree, err := slf.Unmarshal(name, data)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, agree)
+			return nil
+		})
+
+		if err != nil {
 			return nil, err
 		}
-		gwClient.WithEncrypt(rc4)
 
+		return &AgreMsg{name, batch}, nil
 	}
 
-	name, data, err := decoder(slf.getEncrypt(gwClient), c)
-	if err != nil {
-		return nil, err
+	return slf.Unmarshal(name, data)
+}
+
+//AsyncEncodeBatch doc
+//@Summary network data encode method, encode messages into one batch frame
+//@Param   client
+//@Param   need encode datas
+//@Return  encode result
+//@Return  error
+func (slf *DefaultDelegate) AsyncEncodeBatch(c net.INetClient,
+	responses []interface{}) ([]byte, error) {
+	if codec, ok := slf.getCodec(c.(*client)).(*DefaultFrameCodec); ok && codec.Legacy {
+		//protocol version 1 has no batch frame, messages are sent one frame each
+		var result []byte
+		for _, response := range responses {
+			d, err := slf.AsyncEncode(c, response)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, d...)
+		}
+		return result, nil
 	}
 
-	msgType := proto.MessageType(name)
-	if msgType == nil {
-		return nil, fmt.Errorf("%s protocol is undefined", name)
+	names := make([]string, len(responses))
+	sizes := make([]int, len(responses))
+	size := 0
+	for i, response := range responses {
+		if m, ok := response.(*AgreEncoded); ok {
+			names[i], sizes[i] = m.Agreement, len(m.AgreementData)
+		} else {
+			var err error
+			if names[i], err = slf.getMessageName(response); err != nil {
+				return nil, err
+			}
+			sizes[i] = proto.Size(response.(proto.Message))
+		}
+		size += binary.MaxVarintLen32*2 + len(names[i]) + sizes[i]
 	}
 
-	msg := reflect.Indirect(reflect.New(msgType.Elem())).Addr().Interface().(proto.Message)
+	bp := getBuffer(size)
+	defer putBuffer(bp)
+
+	container := *bp
+	offset := 0
+	for i, response := range responses {
+		offset += binary.PutUvarint(container[offset:], uint64(len(names[i])))
+		offset += copy(container[offset:], names[i])
+		offset += binary.PutUvarint(container[offset:], uint64(sizes[i]))
+		if m, ok := response.(*AgreEncoded); ok {
+			offset += copy(container[offset:], m.AgreementData)
+			continue
+		}
 
-	err = proto.Unmarshal(data, msg)
-	if err != nil {
-		return nil, err
+		if err := marshalTo(container[offset:offset+sizes[i]], response.(proto.Message)); err != nil {
+			return nil, err
+		}
+		offset += sizes[i]
 	}
 
-	return &AgreMsg{name, &msg}, nil
+	gwClient := c.(*client)
+	return slf.getCodec(gwClient).Encode(slf.getEncrypt(gwClient), "", container[:offset])
 }
 
 //AsyncEncode doc
@@ -219,14 +221,100 @@ func (slf *DefaultDelegate) AsyncDecode(c net.INetClient) (*AgreMsg, error) {
 //@Return  error
 func (slf *DefaultDelegate) AsyncEncode(c net.INetClient,
 	response interface{}) ([]byte, error) {
+	gwClient := c.(*client)
+	codec := slf.getCodec(gwClient)
+	if m, ok := response.(*AgreEncoded); ok {
+		encrypt := slf.getEncrypt(gwClient)
+		if encrypt == nil {
+			return m.plainFrame(codec)
+		}
+		return codec.Encode(encrypt, m.Agreement, m.AgreementData)
+	}
+
+	msgName, err := slf.getMessageName(response)
+	if err != nil {
+		return nil, err
+	}
+
+	if enc, ok := codec.(IMessageEncoder); ok {
+		return enc.EncodeMessage(slf.getEncrypt(gwClient), msgName, response.(proto.Message))
+	}
+
 	d, err := proto.Marshal(response.(proto.Message))
 	if err != nil {
 		return nil, err
 	}
 
-	msgName := proto.MessageName(response.(proto.Message))
+	return codec.Encode(slf.getEncrypt(gwClient), msgName, d)
+}
+
+//AsyncMarshal doc
+//@Summary marshal a message once for several clients
+//@Param   message
+//@Return  encoded message, it is accepted by AsyncEncode and AsyncEncodeBatch
+//@Return  error
+func (slf *DefaultDelegate) AsyncMarshal(msg interface{}) (*AgreEncoded, error) {
+	msgName, err := slf.getMessageName(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := proto.Marshal(msg.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AgreEncoded{Agreement: msgName, AgreementData: d}, nil
+}
+
+//Unmarshal doc
+//@Summary unmarshal agreement data by agreement name
+//@Param   agreement name
+//@Param   agreement data
+//@Return  AgreMessage
+//@Return  error
+func (slf *DefaultDelegate) Unmarshal(name string, data []byte) (*AgreMsg, error) {
+	msgType, name, err := slf.getMessageType(name)
+	if err != nil {
+		return nil, err
+	}
+
+	//the message is handed to the client actor, it cannot be pooled
+	msg := reflect.New(msgType.Elem()).Interface().(proto.Message)
+
+	if err = proto.Unmarshal(data, msg); err != nil {
+		return nil, code.ErrDataCorrupted
+	}
 
-	return encoder(slf.getEncrypt(c.(*client)), msgName, d), nil
+	return &AgreMsg{name, msg}, nil
+}
+
+func (slf *DefaultDelegate) getMessageType(name string) (reflect.Type, string, error) {
+	if slf.FrameMode == IDFrame {
+		id, err := slf.Registry.DecodeID(name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		msgType, msgName := slf.Registry.GetType(id)
+		if msgType == nil {
+			return nil, "", code.ErrMessageUndefined
+		}
+		return msgType, msgName, nil
+	}
+
+	msgType := proto.MessageType(name)
+	if msgType == nil {
+		return nil, "", code.ErrMessageUndefined
+	}
+	return msgType, name, nil
+}
+
+func (slf *DefaultDelegate) getMessageName(msg interface{}) (string, error) {
+	if slf.FrameMode == IDFrame {
+		return slf.Registry.EncodeID(msg)
+	}
+	return proto.MessageName(msg.(proto.Message)), nil
 }
 
 func (slf *DefaultDelegate) getEncrypt(c *client) encryption.INetEncryption {
@@ -236,10 +324,115 @@ func (slf *DefaultDelegate) getEncrypt(c *client) encryption.INetEncryption {
 	return nil
 }
 
-/*func TestDecoder(encrypt encryption.INetEncryption, bf net.INetReceiveBuffer) (string, []byte, error) {
-	return decoder(encrypt, bf)
+//handshake doc
+//@Summary read client hello, negotiate session parameters and answer server hello
+func (slf *DefaultDelegate) handshake(c *client) error {
+	hello, err := ReadClientHello(c)
+	if err == net.ErrAnalysisProceed {
+		return err
+	}
+
+	if err != nil {
+		return slf.rejectHandshake(c, HandshakeMalformed)
+	}
+
+	if hello.Version < MinProtocolVersion {
+		return slf.rejectHandshake(c, HandshakeVersion)
+	}
+
+	version := hello.Version
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+
+	if c._parent == nil {
+		return code.ErrHandshakeRejected
+	}
+	srv := c._parent
+
+	if hello.Build < srv._minBuild {
+		return slf.rejectHandshake(c, HandshakeBuild)
+	}
+
+	if !srv.isCipherAllowed(int(hello.Cipher)) {
+		return slf.rejectHandshake(c, HandshakeCipher)
+	}
+
+	compress := CompressNone
+	var codec FrameCodec
+	if version < ProtocolVersion && srv._legacyCodec != nil {
+		//frames of protocol version 1 have no flag
+		codec = srv._legacyCodec
+	} else if srv._compressor != nil {
+		if (hello.Compress & CompressMask(srv._compressor.ID())) != 0 {
+			compress = srv._compressor.ID()
+		} else if srv._plainCodec != nil {
+			//frames of the client are not compressed
+			codec = srv._plainCodec
+		} else {
+			return slf.rejectHandshake(c, HandshakeCompress)
+		}
+	}
+
+	secret := slf.KeyExc.Secret(c.GetPrvKey(), hello.PublicKey)
+	secretByte := make([]byte, 8)
+	binary.BigEndian.PutUint64(secretByte, secret)
+	encrypt, err := NewSessionEncrypt(int(hello.Cipher), secretByte, true)
+	if err != nil {
+		return slf.rejectHandshake(c, HandshakeCipher)
+	}
+
+	rsp := &ServerHello{Version: version,
+		Code:      HandshakeOK,
+		Cipher:    hello.Cipher,
+		Compress:  uint8(compress),
+		PublicKey: c.GetPubKey()}
+
+	var handle uint64
+	resumed := false
+	if hello.Features&FeatureResume != 0 {
+		if handle, resumed = srv.claim(hello.Token); resumed {
+			rsp.Features |= FeatureResume
+		}
+	}
+
+	//messages pushed meanwhile are sent after the server hello by the session encryption
+	c._sendSync.Lock()
+	if err = c.SendTo(rsp.Marshal()); err == nil {
+		c._version = version
+		c._build = hello.Build
+		c._codec = codec
+		c.WithEncrypt(encrypt)
+	}
+	c._sendSync.Unlock()
+
+	if err != nil {
+		encrypt.Destory()
+		return err
+	}
+
+	if resumed {
+		return srv.resume(c, handle)
+	}
+	return srv.transit(c, StateUnauthenticated)
+}
+
+func (slf *DefaultDelegate) rejectHandshake(c *client, reason uint8) error {
+	rsp := &ServerHello{Version: ProtocolVersion, Code: reason}
+	c.SendTo(rsp.Marshal())
+	if reason == HandshakeMalformed {
+		return code.ErrHandshakeMalformed
+	}
+	return code.ErrHandshakeRejected
 }
 
-func TestEncoder(encrypt encryption.INetEncryption, dataName string, data []byte) []byte {
-	return encoder(encrypt, dataName, data)
-}*/
+func (slf *DefaultDelegate) getCodec(c *client) FrameCodec {
+	if c._codec != nil {
+		return c._codec
+	}
+
+	if c._parent != nil && c._parent._codec != nil {
+		return c._parent._codec
+	}
+	return defaultCodec
+}
@@ -78,6 +78,11 @@ type clientGroup struct {
 //@Summary initialization gateway server client manage
 //@Method Initial
 func (slf *clientGroup) Initial() {
+	if slf._handles != nil {
+		//shared by listeners of the server
+		return
+	}
+
 	slf._handles = make(map[uint64]net.INetClient)
 	slf._sockets = make(map[int32]net.INetClient)
 	slf._allocer = &clientAllocer{_parent: slf}
@@ -162,26 +167,86 @@ func (slf *clientGroup) GrapSocket(sock int32) net.INetClient {
 //@Param (uint64) a client is (Handle/ID)
 func (slf *clientGroup) Erase(h uint64) {
 	slf._sync.Lock()
-	defer slf._sync.Unlock()
+	c := slf.erase(h)
+	slf._sync.Unlock()
+	slf.free(c)
+}
 
+//erase remove client of handle, returns the client when it is not referenced any more.
+//The lock is held by caller
+func (slf *clientGroup) erase(h uint64) net.INetClient {
 	c, ok := slf._handles[h]
 	if !ok {
-		return
+		return nil
 	}
 
 	s := c.GetSocket()
-	if s > 0 {
+	if s != 0 {
 		if _, ok = slf._sockets[s]; ok {
 			delete(slf._sockets, s)
 		}
 	}
 
 	delete(slf._handles, h)
+	slf._sz--
 
 	if c.DecRef() <= 0 {
+		return c
+	}
+	return nil
+}
+
+//free delete a client not referenced, it is called without the lock because
+//the client shutdown calls the delegate, which may call back into the group
+func (slf *clientGroup) free(c net.INetClient) {
+	if c != nil {
 		slf.Allocer().Delete(c)
 	}
-	slf._sz--
+}
+
+//EraseClient doc
+//@Summary remove client, a client rebound away by a resumed connection is not removed
+//@Param  a client
+//@Return handle of client, 0 when its handle was taken over
+func (slf *clientGroup) EraseClient(c net.INetClient) uint64 {
+	slf._sync.Lock()
+	h := c.GetID()
+	if v, ok := slf._handles[h]; !ok || v != c {
+		slf._sync.Unlock()
+		return 0
+	}
+
+	unused := slf.erase(h)
+	slf._sync.Unlock()
+	slf.free(unused)
+	return h
+}
+
+//Rebind doc
+//@Summary move a client to the handle of a resumed session, a client still holding
+//         the handle is removed from the group and its handle becomes 0
+//@Param  a client
+//@Param  resumed handle
+func (slf *clientGroup) Rebind(c net.INetClient, h uint64) {
+	var unused net.INetClient
+	slf._sync.Lock()
+	if old, ok := slf._handles[h]; ok && old != c {
+		s := old.GetSocket()
+		if v, ok := slf._sockets[s]; ok && s != 0 && v == old {
+			delete(slf._sockets, s)
+		}
+		old.WithID(0)
+		slf._sz--
+		if old.DecRef() <= 0 {
+			unused = old
+		}
+	}
+
+	delete(slf._handles, c.GetID())
+	slf._handles[h] = c
+	c.WithID(h)
+	slf._sync.Unlock()
+	slf.free(unused)
 }
 
 //Release doc
@@ -190,10 +255,11 @@ func (slf *clientGroup) Erase(h uint64) {
 //@Param implement.INetClient a client
 func (slf *clientGroup) Release(c net.INetClient) {
 	slf._sync.Lock()
-	defer slf._sync.Unlock()
+	ref := c.DecRef()
+	slf._sync.Unlock()
 
-	if c.DecRef() <= 0 {
-		slf.Allocer().Delete(c)
+	if ref <= 0 {
+		slf.free(c)
 	}
 }
 
@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"encoding/binary"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+const (
+	//ProtocolVersion current gateway protocol version. Version 2 frame header is 3 bit flag,
+	//21 bit data length and 8 bit data name length, frame data is at most MaxFrameData(2MB)
+	ProtocolVersion = 2
+	//MinProtocolVersion minimum protocol version accepted by gateway. Version 1 frame header
+	//is 24 bit data length and 8 bit data name length without flag, frame data is at most
+	//MaxLegacyFrameData(16MB), see DefaultFrameCodec.Legacy
+	MinProtocolVersion = 1
+)
+
+const (
+	//HandshakeOK handshake accepted
+	HandshakeOK = 0
+	//HandshakeVersion protocol version unsupported
+	HandshakeVersion = 1
+	//HandshakeBuild client build is too old
+	HandshakeBuild = 2
+	//HandshakeCipher requested cipher unsupported
+	HandshakeCipher = 3
+	//HandshakeCompress client unsupported compressor of a custom frame codec, clients of the
+	//default frame codec without the gateway compressor are answered CompressNone
+	HandshakeCompress = 4
+	//HandshakeMalformed malformed handshake message
+	HandshakeMalformed = 5
+)
+
+const (
+	//FeatureResume client hello carries a resume token, server hello echoes it when the session is resumed
+	FeatureResume = 1
+)
+
+const (
+	//"MG"
+	constHandshakeMagic = 0x4D47
+	//magic + body length
+	constHandshakeHeadByte = 4
+	//limit body length
+	constHandshakeMaxLength = 256
+	//version 1 client hello body length
+	constClientHelloLength = 20
+	//resume token length
+	constResumeTokenByte = 16
+	//version 1 server hello body length
+	constServerHelloLength = 19
+)
+
+/*************************************************|
+|  16 Bit  |    16 Bit     |     (N) Bit          |
+|----------|---------------|----------------------|
+|  Magic   |  Body Length  |  Hello Body          |
+**************************************************/
+
+//CompressMask doc
+//@Summary Returns compressor bit of the hello compress mask
+//@Param  compressor id
+func CompressMask(id int) uint8 {
+	if id <= CompressNone || id > 7 {
+		return 0
+	}
+	return uint8(1 << uint(id))
+}
+
+//ClientHello doc
+//@Summary client handshake request
+//@Member  protocol version
+//@Member  client build
+//@Member  requested cipher
+//@Member  supported compressor mask, see CompressMask
+//@Member  feature flags
+//@Member  dh64 public key
+//@Member  resume token, present with FeatureResume
+type ClientHello struct {
+	Version   uint16
+	Build     uint32
+	Cipher    uint8
+	Compress  uint8
+	Features  uint32
+	PublicKey uint64
+	Token     [constResumeTokenByte]byte
+}
+
+//Marshal doc
+//@Summary Returns client hello wire data
+func (slf *ClientHello) Marshal() []byte {
+	length := constClientHelloLength
+	if slf.Features&FeatureResume != 0 {
+		length += constResumeTokenByte
+	}
+
+	result := make([]byte, constHandshakeHeadByte+length)
+	binary.BigEndian.PutUint16(result, constHandshakeMagic)
+	binary.BigEndian.PutUint16(result[2:], uint16(length))
+	body := result[constHandshakeHeadByte:]
+	binary.BigEndian.PutUint16(body, slf.Version)
+	binary.BigEndian.PutUint32(body[2:], slf.Build)
+	body[6] = slf.Cipher
+	body[7] = slf.Compress
+	binary.BigEndian.PutUint32(body[8:], slf.Features)
+	binary.BigEndian.PutUint64(body[12:], slf.PublicKey)
+	if slf.Features&FeatureResume != 0 {
+		copy(body[constClientHelloLength:], slf.Token[:])
+	}
+	return result
+}
+
+//ReadClientHello doc
+//@Summary read a client hello from receive buffer
+//@Param  receive buffer
+//@Return *ClientHello
+//@Return error
+func ReadClientHello(bf net.INetReceiveBuffer) (*ClientHello, error) {
+	body, err := readHello(bf, constClientHelloLength)
+	if err != nil {
+		return nil, err
+	}
+
+	hello := &ClientHello{Version: binary.BigEndian.Uint16(body),
+		Build:     binary.BigEndian.Uint32(body[2:]),
+		Cipher:    body[6],
+		Compress:  body[7],
+		Features:  binary.BigEndian.Uint32(body[8:]),
+		PublicKey: binary.BigEndian.Uint64(body[12:])}
+	if hello.Features&FeatureResume != 0 {
+		if len(body) < constClientHelloLength+constResumeTokenByte {
+			return nil, code.ErrHandshakeMalformed
+		}
+		copy(hello.Token[:], body[constClientHelloLength:])
+	}
+
+	return hello, nil
+}
+
+//ServerHello doc
+//@Summary gateway handshake response
+//@Member  protocol version chosen
+//@Member  result code, HandshakeOK or rejected reason
+//@Member  cipher chosen
+//@Member  compressor chosen, CompressNone is not compress
+//@Member  feature flags chosen
+//@Member  dh64 public key
+type ServerHello struct {
+	Version   uint16
+	Code      uint8
+	Cipher    uint8
+	Compress  uint8
+	Features  uint32
+	PublicKey uint64
+}
+
+//Marshal doc
+//@Summary Returns server hello wire data
+func (slf *ServerHello) Marshal() []byte {
+	result := make([]byte, constHandshakeHeadByte+constServerHelloLength)
+	binary.BigEndian.PutUint16(result, constHandshakeMagic)
+	binary.BigEndian.PutUint16(result[2:], constServerHelloLength)
+	body := result[constHandshakeHeadByte:]
+	binary.BigEndian.PutUint16(body, slf.Version)
+	body[2] = slf.Code
+	body[3] = slf.Cipher
+	body[4] = slf.Compress
+	binary.BigEndian.PutUint32(body[5:], slf.Features)
+	binary.BigEndian.PutUint64(body[9:], slf.PublicKey)
+	return result
+}
+
+//ReadServerHello doc
+//@Summary read a server hello from receive buffer
+//@Param  receive buffer
+//@Return *ServerHello
+//@Return error
+func ReadServerHello(bf net.INetReceiveBuffer) (*ServerHello, error) {
+	body, err := readHello(bf, constServerHelloLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServerHello{Version: binary.BigEndian.Uint16(body),
+		Code:      body[2],
+		Cipher:    body[3],
+		Compress:  body[4],
+		Features:  binary.BigEndian.Uint32(body[5:]),
+		PublicKey: binary.BigEndian.Uint64(body[9:])}, nil
+}
+
+//readHello read hello body, newer versions may append fields to the body
+func readHello(bf net.INetReceiveBuffer, minLength int) ([]byte, error) {
+	if bf.GetBufferLen() < constHandshakeHeadByte {
+		return nil, net.ErrAnalysisProceed
+	}
+
+	head := bf.GetBufferBytes()[:constHandshakeHeadByte]
+	if binary.BigEndian.Uint16(head) != constHandshakeMagic {
+		return nil, code.ErrHandshakeMalformed
+	}
+
+	length := int(binary.BigEndian.Uint16(head[2:]))
+	if length < minLength || length > constHandshakeMaxLength {
+		return nil, code.ErrHandshakeMalformed
+	}
+
+	if bf.GetBufferLen() < constHandshakeHeadByte+length {
+		return nil, net.ErrAnalysisProceed
+	}
+
+	bf.TrunBuffer(constHandshakeHeadByte)
+	return bf.ReadBuffer(length), nil
+}
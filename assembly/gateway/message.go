@@ -1,7 +1,49 @@
 package gateway
 
+import "sync"
+
 //AgreMsg Protocol messages from the network
 type AgreMsg struct {
 	Agreement     interface{}
 	AgreementData interface{}
 }
+
+//AgreBatch Protocol messages unpacked from a batch frame, in order
+type AgreBatch []*AgreMsg
+
+//encodedFrame frame of a codec
+type encodedFrame struct {
+	_data []byte
+	_err  error
+}
+
+//AgreEncoded doc
+//@Summary a message marshaled once for several clients, the frame of clients without
+//         session encryption is built once per codec and shared
+//@Member  agreement name
+//@Member  agreement data
+type AgreEncoded struct {
+	Agreement     string
+	AgreementData []byte
+	_plain        map[FrameCodec]encodedFrame
+	_plainSync    sync.Mutex
+}
+
+//plainFrame returns the shared frame of clients of the codec without session encryption,
+//clients without the compressor have their own codec
+func (slf *AgreEncoded) plainFrame(codec FrameCodec) ([]byte, error) {
+	slf._plainSync.Lock()
+	defer slf._plainSync.Unlock()
+
+	if f, ok := slf._plain[codec]; ok {
+		return f._data, f._err
+	}
+
+	if slf._plain == nil {
+		slf._plain = make(map[FrameCodec]encodedFrame, 1)
+	}
+
+	data, err := codec.Encode(nil, slf.Agreement, slf.AgreementData)
+	slf._plain[codec] = encodedFrame{_data: data, _err: err}
+	return data, err
+}
@@ -0,0 +1,36 @@
+package gateway
+
+import "sync/atomic"
+
+//Metrics doc
+//@Summary gateway counters
+//@Member  agreements rejected by auth level
+//@Member  agreements over rate limit
+//@Member  clients closed by rate limit
+//@Member  connections rejected by admission control
+//@Member  ips banned by protocol errors
+type Metrics struct {
+	AuthRejected   uint64
+	RateLimited    uint64
+	RateClosed     uint64
+	AcceptRejected uint64
+	AutoBanned     uint64
+}
+
+type metrics struct {
+	_authRejected   uint64
+	_rateLimited    uint64
+	_rateClosed     uint64
+	_acceptRejected uint64
+	_autoBanned     uint64
+}
+
+//Metrics doc
+//@Summary Returns a snapshot of gateway counters
+func (slf *Server) Metrics() Metrics {
+	return Metrics{AuthRejected: atomic.LoadUint64(&slf._metrics._authRejected),
+		RateLimited:    atomic.LoadUint64(&slf._metrics._rateLimited),
+		RateClosed:     atomic.LoadUint64(&slf._metrics._rateClosed),
+		AcceptRejected: atomic.LoadUint64(&slf._metrics._acceptRejected),
+		AutoBanned:     atomic.LoadUint64(&slf._metrics._autoBanned)}
+}
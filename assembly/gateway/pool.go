@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+const (
+	//pooled buffer initial capacity
+	constPoolBufferSize = 4096
+	//buffers larger than this are not returned to pool
+	constPoolBufferMax = 64 * 1024
+	//maximum agreement names interned by codec
+	constNameCacheMax = 4096
+)
+
+var (
+	bufferPool = sync.Pool{New: func() interface{} {
+		b := make([]byte, 0, constPoolBufferSize)
+		return &b
+	}}
+
+	headPool = sync.Pool{New: func() interface{} {
+		return new([constHeadByte]byte)
+	}}
+)
+
+//getBuffer returns a pooled buffer of length n
+func getBuffer(n int) *[]byte {
+	bp := bufferPool.Get().(*[]byte)
+	if cap(*bp) < n {
+		*bp = make([]byte, n)
+	}
+	*bp = (*bp)[:n]
+	return bp
+}
+
+//putBuffer returns buffer to pool
+func putBuffer(bp *[]byte) {
+	if cap(*bp) > constPoolBufferMax {
+		return
+	}
+	*bp = (*bp)[:0]
+	bufferPool.Put(bp)
+}
+
+//nameCache interned agreement names, decoding a known name does not allocate.
+//Only names of registered proto messages are interned, the map is copied on write
+//and read without lock
+type nameCache struct {
+	_names atomic.Value
+	_sync  sync.Mutex
+}
+
+func (slf *nameCache) intern(b []byte) string {
+	names, _ := slf._names.Load().(map[string]string)
+	if name, ok := names[string(b)]; ok {
+		return name
+	}
+
+	name := string(b)
+	if proto.MessageType(name) == nil {
+		//names from network are not trusted
+		return name
+	}
+
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	names, _ = slf._names.Load().(map[string]string)
+	if _, ok := names[name]; ok || len(names) >= constNameCacheMax {
+		return name
+	}
+
+	tmpNames := make(map[string]string, len(names)+1)
+	for k, v := range names {
+		tmpNames[k] = v
+	}
+	tmpNames[name] = name
+	slf._names.Store(tmpNames)
+	return name
+}
+
+//marshalTo marshal a message to dst, dst length must be proto.Size(msg)
+func marshalTo(dst []byte, msg proto.Message) error {
+	if m, ok := msg.(sizedMarshaler); ok {
+		n, err := m.MarshalToSizedBuffer(dst)
+		if err != nil {
+			return err
+		}
+
+		if n != len(dst) {
+			return code.ErrDataCorrupted
+		}
+		return nil
+	}
+
+	d, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if len(d) != len(dst) {
+		return code.ErrDataCorrupted
+	}
+	copy(dst, d)
+	return nil
+}
@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"reflect"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/service"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+//Send doc
+//@Summary push a message to a client, the message is kept when the client session is detached
+//@Param  client handle
+//@Param  message
+//@Return error, code.ErrClientUndefined when the client is unknown or closed
+func (slf *Server) Send(handle uint64, msg proto.Message) error {
+	m, err := slf._delegate.AsyncMarshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return slf.push(handle, m)
+}
+
+//Multicast doc
+//@Summary push a message to clients, the message is marshaled once
+//@Param  client handles
+//@Param  message
+//@Return handles of clients unknown, closed or failed
+//@Return error of marshal
+func (slf *Server) Multicast(handles []uint64, msg proto.Message) ([]uint64, error) {
+	m, err := slf._delegate.AsyncMarshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []uint64
+	for _, h := range handles {
+		if slf.push(h, m) != nil {
+			failed = append(failed, h)
+		}
+	}
+
+	return failed, nil
+}
+
+//Broadcast doc
+//@Summary push a message to all clients completed handshake
+//@Param  message
+//@Return handles of clients closed or failed
+//@Return error of marshal
+func (slf *Server) Broadcast(msg proto.Message) ([]uint64, error) {
+	m, err := slf._delegate.AsyncMarshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []uint64
+	for _, h := range slf._group.GetHandles() {
+		if err = slf.push(h, m); err != nil && err != code.ErrClientHandshaking {
+			failed = append(failed, h)
+		}
+	}
+
+	return failed, nil
+}
+
+//deliverCtrl downstream rpc of route control links, see service.DeliverMethod
+type deliverCtrl struct {
+	_parent *Server
+}
+
+//Deliver push a backend message to clients
+func (slf *deliverCtrl) Deliver(c net.INetClient, request *service.DeliverReq) {
+	msgType := proto.MessageType(request.Agreement)
+	if msgType == nil {
+		slf._parent._listenHandle.LogError("deliver %s undefined", request.Agreement)
+		return
+	}
+
+	msg := reflect.New(msgType.Elem()).Interface().(proto.Message)
+	if err := proto.Unmarshal(request.Data, msg); err != nil {
+		slf._parent._listenHandle.LogError("deliver %s %s", request.Agreement, err.Error())
+		return
+	}
+
+	failed, err := slf._parent.Multicast(request.ClientHandles, msg)
+	if err != nil {
+		slf._parent._listenHandle.LogError("deliver %s %s", request.Agreement, err.Error())
+		return
+	}
+
+	if len(failed) > 0 {
+		slf._parent._listenHandle.LogDebug("deliver %s failed clients %+v", request.Agreement, failed)
+	}
+}
@@ -0,0 +1,147 @@
+package gateway
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//RateDrop message over rate limit is dropped
+	RateDrop = 0
+	//RateError message over rate limit is answered by AgreementError
+	RateError = 1
+	//RateClose message over rate limit closes the client
+	RateClose = 2
+)
+
+const (
+	//ErrorRateLimited AgreementError code, message is over rate limit
+	ErrorRateLimited = 2
+)
+
+//RateLimit doc
+//@Summary token bucket limit
+//@Member  messages per second, 0 is unlimited
+//@Member  messages sent at once after idle, at least 1
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+//TokenBucket doc
+//@Summary token bucket of a rate limit, it is not goroutine safe
+type TokenBucket struct {
+	_rate   float64
+	_burst  float64
+	_tokens float64
+	_last   int64
+}
+
+//NewTokenBucket doc
+//@Summary Returns a full token bucket
+//@Param  rate limit
+func NewTokenBucket(limit RateLimit) *TokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{_rate: limit.Rate, _burst: burst, _tokens: burst}
+}
+
+//Take doc
+//@Summary take a token, returns false when the bucket is empty
+//@Param  now in nanosecond
+func (slf *TokenBucket) Take(now int64) bool {
+	if slf._last != 0 && now > slf._last {
+		slf._tokens += float64(now-slf._last) * slf._rate / float64(time.Second)
+		if slf._tokens > slf._burst {
+			slf._tokens = slf._burst
+		}
+	}
+	slf._last = now
+
+	if slf._tokens < 1 {
+		return false
+	}
+	slf._tokens--
+	return true
+}
+
+//refund put back a token taken
+func (slf *TokenBucket) refund() {
+	if slf._tokens++; slf._tokens > slf._burst {
+		slf._tokens = slf._burst
+	}
+}
+
+//rateLimiter buckets of a client, agreements of override limit take both their bucket and the global bucket
+type rateLimiter struct {
+	_global *TokenBucket
+	_limits map[string]*TokenBucket
+	_sync   sync.Mutex
+}
+
+func (slf *Server) newRateLimiter() *rateLimiter {
+	if slf._rateLimit.Rate <= 0 && len(slf._rateLimits) == 0 {
+		return nil
+	}
+
+	r := &rateLimiter{}
+	if slf._rateLimit.Rate > 0 {
+		r._global = NewTokenBucket(slf._rateLimit)
+	}
+
+	if len(slf._rateLimits) > 0 {
+		r._limits = make(map[string]*TokenBucket, len(slf._rateLimits))
+		for name, limit := range slf._rateLimits {
+			if limit.Rate > 0 {
+				r._limits[name] = NewTokenBucket(limit)
+			}
+		}
+	}
+	return r
+}
+
+//allow returns false when the agreement is over rate limit
+func (slf *rateLimiter) allow(agreement string, now int64) bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	b, ok := slf._limits[agreement]
+	if ok && !b.Take(now) {
+		return false
+	}
+
+	if slf._global != nil && !slf._global.Take(now) {
+		//the message is not sent, it doesn't count against the agreement
+		if ok {
+			b.refund()
+		}
+		return false
+	}
+	return true
+}
+
+//limitRate check rate limit of the agreement, returns whether it is dispatched
+//and whether the client is still open
+func (slf *Server) limitRate(c *client, req *AgreMsg, now int64) (bool, bool) {
+	if c._limiter == nil || c._limiter.allow(req.Agreement.(string), now) {
+		return true, true
+	}
+
+	atomic.AddUint64(&slf._metrics._rateLimited, 1)
+	switch slf._rateAction {
+	case RateClose:
+		atomic.AddUint64(&slf._metrics._rateClosed, 1)
+		c.LogError("client %s => %d %s over rate limit, closed", c.GetAddr(), c.GetSocket(), req.Agreement.(string))
+		c.close()
+		return false, false
+	case RateError:
+		rsp := &AgreementError{Agreement: req.Agreement.(string), Code: ErrorRateLimited}
+		if err := slf.sendTo(c, rsp); err != nil {
+			c.LogError("reject client %s => %d %s", c.GetAddr(), c.GetSocket(), err.Error())
+		}
+	}
+	return false, true
+}
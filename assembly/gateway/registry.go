@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+const (
+	//MessageID16 16 bit message id
+	MessageID16 = 2
+	//MessageID32 32 bit message id
+	MessageID32 = 4
+)
+
+//NewMessageRegistry doc
+//@Summary Create a message id registry
+//@Param  id size MessageID16 or MessageID32
+//@Return *MessageRegistry
+func NewMessageRegistry(size int) *MessageRegistry {
+	if size != MessageID16 {
+		size = MessageID32
+	}
+
+	return &MessageRegistry{_size: size,
+		_types: make(map[uint32]reflect.Type),
+		_names: make(map[uint32]string),
+		_ids:   make(map[reflect.Type]uint32)}
+}
+
+//MessageRegistry doc
+//@Summary proto message <=> numeric id registry
+type MessageRegistry struct {
+	_size  int
+	_types map[uint32]reflect.Type
+	_names map[uint32]string
+	_ids   map[reflect.Type]uint32
+	_sync  sync.RWMutex
+}
+
+//Size doc
+//@Summary Returns message id size(byte)
+func (slf *MessageRegistry) Size() int {
+	return slf._size
+}
+
+//Register doc
+//@Summary register a message with a specified id
+//@Param  message id
+//@Param  message object
+//@Return error
+func (slf *MessageRegistry) Register(id uint32, msg proto.Message) error {
+	if slf._size == MessageID16 && id > 0xFFFF {
+		return code.ErrMessageIDOverflow
+	}
+
+	t := reflect.TypeOf(msg)
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	if v, ok := slf._types[id]; ok {
+		if v == t {
+			return nil
+		}
+		return code.ErrMessageIDConflict
+	}
+
+	if _, ok := slf._ids[t]; ok {
+		return code.ErrMessageRegistered
+	}
+
+	slf._types[id] = t
+	slf._names[id] = proto.MessageName(msg)
+	slf._ids[t] = id
+
+	return nil
+}
+
+//RegisterHash doc
+//@Summary register a message, id is derived from the hash of message full name
+//@Param  message object
+//@Return message id
+//@Return error
+func (slf *MessageRegistry) RegisterHash(msg proto.Message) (uint32, error) {
+	id := slf.HashID(proto.MessageName(msg))
+	if err := slf.Register(id, msg); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//HashID doc
+//@Summary Returns the hash id of message full name
+//@Param  message full name
+//@Return message id
+func (slf *MessageRegistry) HashID(name string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	id := h.Sum32()
+	if slf._size == MessageID16 {
+		id = (id >> 16) ^ (id & 0xFFFF)
+	}
+	return id
+}
+
+//GetID doc
+//@Summary Returns message id
+//@Param  message object
+//@Return message id
+//@Return is registered
+func (slf *MessageRegistry) GetID(msg interface{}) (uint32, bool) {
+	slf._sync.RLock()
+	defer slf._sync.RUnlock()
+
+	id, ok := slf._ids[reflect.TypeOf(msg)]
+	return id, ok
+}
+
+//GetType doc
+//@Summary Returns message type and full name
+//@Param  message id
+//@Return message type, nil is unregistered
+//@Return message full name
+func (slf *MessageRegistry) GetType(id uint32) (reflect.Type, string) {
+	slf._sync.RLock()
+	defer slf._sync.RUnlock()
+
+	if t, ok := slf._types[id]; ok {
+		return t, slf._names[id]
+	}
+	return nil, ""
+}
+
+//EncodeID doc
+//@Summary Returns frame agreement name of a registered message
+//@Param  message object
+//@Return agreement name
+//@Return error
+func (slf *MessageRegistry) EncodeID(msg interface{}) (string, error) {
+	id, ok := slf.GetID(msg)
+	if !ok {
+		return "", code.ErrMessageUnregistered
+	}
+
+	tmpByte := make([]byte, slf._size)
+	if slf._size == MessageID16 {
+		binary.BigEndian.PutUint16(tmpByte, uint16(id))
+	} else {
+		binary.BigEndian.PutUint32(tmpByte, id)
+	}
+
+	return string(tmpByte), nil
+}
+
+//DecodeID doc
+//@Summary Returns message id of frame agreement name
+//@Param  agreement name
+//@Return message id
+//@Return error
+func (slf *MessageRegistry) DecodeID(name string) (uint32, error) {
+	if len(name) != slf._size {
+		return 0, code.ErrMessageUnregistered
+	}
+
+	if slf._size == MessageID16 {
+		return uint32(binary.BigEndian.Uint16([]byte(name))), nil
+	}
+	return binary.BigEndian.Uint32([]byte(name)), nil
+}
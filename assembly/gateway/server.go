@@ -1,10 +1,14 @@
 package gateway
 
 import (
-	"errors"
+	"crypto/tls"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/yamakiller/magicGame/assembly/code"
 	"github.com/yamakiller/magicGame/assembly/service"
 	"github.com/yamakiller/magicLibs/coroutine"
 	"github.com/yamakiller/magicLibs/util"
@@ -24,21 +28,60 @@ const (
 	TCPNet = 0
 	//WWSNet websocket mode
 	WWSNet = 1
+	//UDPNet reliable udp mode
+	UDPNet = 2
+)
+
+const (
+	//AuthReject agreement under auth level is answered by AgreementError
+	AuthReject = 0
+	//AuthClose agreement under auth level closes the client
+	AuthClose = 1
+)
+
+const (
+	//ErrorUnauthorized AgreementError code, client auth level is under the agreement level
+	ErrorUnauthorized = 1
+	//ErrorRouteBusy AgreementError code, too many routed agreements of client are in progress
+	ErrorRouteBusy = 3
 )
 
 //Options Gateway Server Options
 type Options struct {
-	Name          string
-	ServerID      int
-	SocketMode    int
-	BufferCap     int
-	KeepTime      int
-	OutCChanSize  int
-	Cap           int
-	Replicas      int
-	AuthTimeout   int64
-	GuardInterval int64
-	Delegate      IServerDelegate
+	Name             string
+	ServerID         int
+	SocketMode       int
+	BufferCap        int
+	KeepTime         int
+	OutCChanSize     int
+	Cap              int
+	Replicas         int
+	AuthTimeout      int64
+	HandshakeTimeout int64
+	IdleTimeout      int64
+	DrainTimeout     int64
+	GuardInterval    int64
+	Delegate         IServerDelegate
+	Codec            FrameCodec
+	Compressor       Compressor
+	CompressLimit    int
+	FragmentSize     int
+	MaxMessage       int
+	BatchWindow      int64
+	BatchLimit       int
+	Ciphers          []int
+	MinBuild         uint32
+	TLS              *tls.Config
+	ResumeGrace      int64
+	ResumeBuffer     int
+	AuthPolicy       int
+	Authenticator    Authenticator
+	RateLimit        RateLimit
+	RateLimits       map[string]RateLimit
+	RateAction       int
+	MaxConnPerIP     int
+	AcceptRate       RateLimit
+	AutoBan          AutoBan
 }
 
 //Option Gateway Server Option function
@@ -60,6 +103,14 @@ func WithID(id int) Option {
 	}
 }
 
+//WithSocketMode Set listen mode, TCPNet, WWSNet or UDPNet
+func WithSocketMode(mode int) Option {
+	return func(o *Options) error {
+		o.SocketMode = mode
+		return nil
+	}
+}
+
 //WithClientCap Set accesser cap option
 func WithClientCap(cap int) Option {
 	return func(o *Options) error {
@@ -84,7 +135,8 @@ func WithRouteReplicas(reps int) Option {
 	}
 }
 
-//WithClientOutChanSize Set the connection client transaction pipeline buffer size
+//WithClientOutChanSize Set the connection client transaction pipeline buffer size,
+//gateway owned connections queue this many frames and are closed when the queue is full
 func WithClientOutChanSize(ch int) Option {
 	return func(o *Options) error {
 		o.OutCChanSize = ch
@@ -100,7 +152,7 @@ func WithClientKeepTime(tm int) Option {
 	}
 }
 
-//WithAuthTimeout Set client auth time out
+//WithAuthTimeout Set time out of unauthenticated client after the handshake, 0 is never
 func WithAuthTimeout(tm int64) Option {
 	return func(o *Options) error {
 		o.AuthTimeout = tm
@@ -108,6 +160,30 @@ func WithAuthTimeout(tm int64) Option {
 	}
 }
 
+//WithHandshakeTimeout Set client handshake time out, 0 is never
+func WithHandshakeTimeout(tm int64) Option {
+	return func(o *Options) error {
+		o.HandshakeTimeout = tm
+		return nil
+	}
+}
+
+//WithIdleTimeout Set time out of authenticated client receiving nothing, 0 is never
+func WithIdleTimeout(tm int64) Option {
+	return func(o *Options) error {
+		o.IdleTimeout = tm
+		return nil
+	}
+}
+
+//WithDrainTimeout Set time of draining client before closed, see Server.Drain
+func WithDrainTimeout(tm int64) Option {
+	return func(o *Options) error {
+		o.DrainTimeout = tm
+		return nil
+	}
+}
+
 //WithGuardInterval Set Server guard interval time
 func WithGuardInterval(tm int64) Option {
 	return func(o *Options) error {
@@ -124,61 +200,265 @@ func WithDelegate(delegate IServerDelegate) Option {
 	}
 }
 
+//WithFrameCodec Set Server frame codec, default is DefaultFrameCodec
+func WithFrameCodec(codec FrameCodec) Option {
+	return func(o *Options) error {
+		o.Codec = codec
+		return nil
+	}
+}
+
+//WithCompression Set frame compressor of the default frame codec,
+//data length greater than the threshold will be compressed.
+//Frames of clients not supporting the compressor are not compressed
+func WithCompression(c Compressor, threshold int) Option {
+	return func(o *Options) error {
+		o.Compressor = c
+		o.CompressLimit = threshold
+		return nil
+	}
+}
+
+//WithFragment Set fragment data length of the default frame codec and
+//maximum message length of reassembled, fragment requires the maximum message length
+func WithFragment(size, maxMessage int) Option {
+	return func(o *Options) error {
+		if maxMessage <= 0 {
+			return code.ErrFragmentUnsupported
+		}
+		o.FragmentSize = size
+		o.MaxMessage = maxMessage
+		return nil
+	}
+}
+
+//WithBatch Set batch flush window in milliseconds and batch size limit,
+//messages to a client within the window are packed into one frame
+func WithBatch(window int64, limit int) Option {
+	return func(o *Options) error {
+		o.BatchWindow = window
+		o.BatchLimit = limit
+		return nil
+	}
+}
+
+//WithCipher Set session ciphers accepted in handshake, CipherRC4, CipherAESGCM or CipherChaCha20,
+//default accept all
+func WithCipher(modes ...int) Option {
+	return func(o *Options) error {
+		for _, mode := range modes {
+			if mode != CipherRC4 && mode != CipherAESGCM && mode != CipherChaCha20 {
+				return code.ErrCipherUnsupported
+			}
+		}
+		o.Ciphers = modes
+		return nil
+	}
+}
+
+//WithMinClientBuild Set minimum client build accepted in handshake
+func WithMinClientBuild(build uint32) Option {
+	return func(o *Options) error {
+		o.MinBuild = build
+		return nil
+	}
+}
+
+//WithTLS Set tls certificate and key files, the gateway terminates tls of tls:// and wss:// listeners
+//and of tcp and websocket listeners without scheme
+func WithTLS(certFile, keyFile string) Option {
+	return func(o *Options) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		o.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		return nil
+	}
+}
+
+//WithTLSConfig Set tls config, the gateway terminates tls of tls:// and wss:// listeners
+//and of tcp and websocket listeners without scheme
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *Options) error {
+		o.TLS = config
+		return nil
+	}
+}
+
+//WithResume Set session resume grace period in milliseconds and limit of messages kept for a
+//dropped client. Authenticated clients receive a ResumeToken, a client reconnecting with the
+//token in time gets the same handle back and missed messages, default disabled.
+//The buffer is at least 1 when grace is set, a session of buffer overflow is dropped
+func WithResume(grace int64, buffer int) Option {
+	return func(o *Options) error {
+		if grace > 0 && buffer <= 0 {
+			return code.ErrResumeBuffer
+		}
+		o.ResumeGrace = grace
+		o.ResumeBuffer = buffer
+		return nil
+	}
+}
+
+//WithAuthPolicy Set action of agreement under auth level, AuthReject or AuthClose
+func WithAuthPolicy(policy int) Option {
+	return func(o *Options) error {
+		o.AuthPolicy = policy
+		return nil
+	}
+}
+
+//WithAuthenticator Set authenticator of login messages, see TokenAuthenticator
+func WithAuthenticator(a Authenticator) Option {
+	return func(o *Options) error {
+		o.Authenticator = a
+		return nil
+	}
+}
+
+//WithRateLimit Set messages per second and burst of a client, 0 rate is unlimited
+func WithRateLimit(rate float64, burst int) Option {
+	return func(o *Options) error {
+		o.RateLimit = RateLimit{Rate: rate, Burst: burst}
+		return nil
+	}
+}
+
+//WithAgreementRateLimit Set messages per second and burst of a client for the agreement,
+//the agreement is limited by the global rate limit too
+func WithAgreementRateLimit(agreement proto.Message, rate float64, burst int) Option {
+	return func(o *Options) error {
+		if o.RateLimits == nil {
+			o.RateLimits = make(map[string]RateLimit)
+		}
+		o.RateLimits[proto.MessageName(agreement)] = RateLimit{Rate: rate, Burst: burst}
+		return nil
+	}
+}
+
+//WithRateAction Set action of message over rate limit, RateDrop, RateError or RateClose
+func WithRateAction(action int) Option {
+	return func(o *Options) error {
+		o.RateAction = action
+		return nil
+	}
+}
+
+//WithMaxConnPerIP Set connections of an ip at most, 0 is unlimited
+func WithMaxConnPerIP(n int) Option {
+	return func(o *Options) error {
+		o.MaxConnPerIP = n
+		return nil
+	}
+}
+
+//WithAcceptRate Set connection attempts per second and burst of an ip, 0 rate is unlimited
+func WithAcceptRate(rate float64, burst int) Option {
+	return func(o *Options) error {
+		o.AcceptRate = RateLimit{Rate: rate, Burst: burst}
+		return nil
+	}
+}
+
+//WithAutoBan Set ban of an ip sending protocol errors, errors in window(millisecond)
+//ban the ip for duration(millisecond, 0 is forever)
+func WithAutoBan(errors int, window, duration int64) Option {
+	return func(o *Options) error {
+		o.AutoBan = AutoBan{Errors: errors, Window: window, Duration: duration}
+		return nil
+	}
+}
+
 var (
 	defaultOption = Options{Name: "Gateway",
-		ServerID:      1,
-		SocketMode:    TCPNet,
-		BufferCap:     8196,
-		KeepTime:      5 * 1000,
-		OutCChanSize:  32,
-		Cap:           4096,
-		Replicas:      32,
-		AuthTimeout:   2 * 1000,
-		GuardInterval: 5 * 1000,
+		ServerID:         1,
+		SocketMode:       TCPNet,
+		BufferCap:        8196,
+		KeepTime:         5 * 1000,
+		OutCChanSize:     32,
+		Cap:              4096,
+		Replicas:         32,
+		AuthTimeout:      2 * 1000,
+		HandshakeTimeout: 2 * 1000,
+		DrainTimeout:     1000,
+		GuardInterval:    5 * 1000,
 	}
 )
 
 //New Create a gateway service and set related parameters
 func New(options ...Option) (*Server, error) {
-	opts := Options{}
+	opts := defaultOption
 	for _, opt := range options {
 		if err := opt(&opts); err != nil {
 			return nil, err
 		}
 	}
 
-	srv := &Server{}
-	handler.Spawn(opts.Name, func() handler.IService {
-		cGroup := &clientGroup{_id: opts.ServerID, _bfSize: opts.BufferCap, _cap: opts.Cap}
-		var s net.INetListener
-		if opts.SocketMode == TCPNet {
-			s = &net.TCPListen{}
-		} else {
-			s = &net.WSSListen{}
-		}
+	if opts.TLS != nil && opts.SocketMode == UDPNet {
+		return nil, code.ErrTLSUnsupported
+	}
 
-		h, err := listener.Spawn(
-			listener.WithListener(s),
-			listener.WithAsyncError(srv.asyncError),
-			listener.WithClientKeepTime(opts.KeepTime),
-			listener.WithClientOutChanSize(opts.OutCChanSize),
-			listener.WithAsyncComplete(srv.asyncComplate),
-			listener.WithAsyncAccept(srv.asyncAccept),
-			listener.WithAsyncClosed(srv.asyncClosed),
-			listener.WithClientGroups(cGroup),
-			listener.WithClientDecoder(srv.defaultDecode),
-		)
+	if d, ok := opts.Delegate.(*DefaultDelegate); ok && d.FrameMode == IDFrame && d.Registry == nil {
+		return nil, code.ErrMessageUnregistered
+	}
 
+	if opts.Codec != nil && opts.MaxMessage > 0 {
+		//fragment options are of the default frame codec
+		return nil, code.ErrFragmentUnsupported
+	}
+
+	srv := &Server{}
+	handler.Spawn(opts.Name, func() handler.IService {
+		srv._name = opts.Name
+		srv._mode = opts.SocketMode
+		srv._tls = opts.TLS
+		srv._keepTime = opts.KeepTime
+		srv._outCChanSize = opts.OutCChanSize
+		srv._listenRet = make(chan error, 1)
+		srv._group = &clientGroup{_id: opts.ServerID, _bfSize: opts.BufferCap, _cap: opts.Cap}
+		h, err := srv.spawnListen(opts.SocketMode, opts.TLS != nil, srv._listenRet)
 		if err != nil {
 			return nil
 		}
 
-		srv._name = opts.Name
 		srv._listenHandle = h
 		srv._delegate = opts.Delegate
+		srv._batchWindow = opts.BatchWindow
+		srv._batchLimit = opts.BatchLimit
+		srv._batchDirty = make(map[uint64]struct{})
+		srv._ciphers = opts.Ciphers
+		srv._minBuild = opts.MinBuild
+		if opts.ResumeGrace > 0 {
+			srv._sessions = newSessionSet(opts.ResumeGrace, opts.ResumeBuffer)
+		}
+		srv._compressor = opts.Compressor
+		srv._codec = opts.Codec
+		if srv._codec == nil {
+			srv._codec = &DefaultFrameCodec{Compressor: opts.Compressor,
+				Threshold:      opts.CompressLimit,
+				FragmentSize:   opts.FragmentSize,
+				MaxMessageSize: opts.MaxMessage}
+			if opts.Compressor != nil {
+				srv._plainCodec = &DefaultFrameCodec{FragmentSize: opts.FragmentSize,
+					MaxMessageSize: opts.MaxMessage}
+			}
+			srv._legacyCodec = &DefaultFrameCodec{MaxMessageSize: opts.MaxMessage, Legacy: true}
+		}
 		srv._authTimeout = opts.AuthTimeout
+		srv._handshakeTimeout = opts.HandshakeTimeout
+		srv._idleTimeout = opts.IdleTimeout
+		srv._drainTimeout = opts.DrainTimeout
 		srv._guardInterval = opts.GuardInterval
 		srv._rss = NewRouteSet(opts.Replicas)
+		srv._topics = newTopicSet()
+		srv._timeouts = NewDeadlineQueue()
+		srv._authPolicy = opts.AuthPolicy
+		srv._authenticator = opts.Authenticator
+		srv._rateLimit = opts.RateLimit
+		srv._rateLimits = opts.RateLimits
+		srv._rateAction = opts.RateAction
+		srv._admission = newAdmission(opts.MaxConnPerIP, opts.AcceptRate, opts.AutoBan)
 		srv._rssCtrlID = util.NewSnowFlake(int64(0), int64(opts.ServerID))
 		srv._listenHandle.Initial()
 		return srv._listenHandle
@@ -191,30 +471,70 @@ func New(options ...Option) (*Server, error) {
 //@Summary gateway server delegate interface
 //@Member AsyncDecode network data decode method
 //@Member AsyncEncode network data encode method
+//@Member AsyncEncodeBatch network data encode method of batch frame
+//@Member AsyncMarshal message marshal method of push
 //@Member AsynAccept  client accept method
 //@Member AsynClosed  client closed method
-//@Member QueryLocalAgreement query agreement local method
+//@Member AsyncState  client session state changed method
+//@Member PutLocalCall register agreement local method
+//@Member PutAgreement register agreement local or route method
 type IServerDelegate interface {
 	AsyncDecode(net.INetClient) (*AgreMsg, error)
 	AsyncEncode(net.INetClient, interface{}) ([]byte, error)
+	AsyncEncodeBatch(net.INetClient, []interface{}) ([]byte, error)
+	AsyncMarshal(interface{}) (*AgreEncoded, error)
 	AsyncAccept(net.INetClient) error
 	AsyncClosed(uint64) error
-	PutLocalCall(interface{}, interface{})
-	getLocalCall(interface{}) interface{}
+	AsyncState(net.INetClient, SessionState, SessionState)
+	PutLocalCall(interface{}, interface{}) error
+	PutAgreement(interface{}, *DefaultAgreement) error
+	getAgreement(interface{}) *DefaultAgreement
 }
 
 //Server doc: Gateway Server
 type Server struct {
-	_name          string
-	_listenHandle  *listener.NetListener
-	_listenWait    sync.WaitGroup
-	_delegate      IServerDelegate
-	_rss           *RouteSet
-	_rssCtrlID     *util.SnowFlake
-	_authTimeout   int64
-	_guardInterval int64
-	_err           error
-	_ishutdown     bool
+	_name             string
+	_mode             int
+	_tls              *tls.Config
+	_keepTime         int
+	_outCChanSize     int
+	_listenHandle     *listener.NetListener
+	_listens          []*listener.NetListener
+	_listened         bool
+	_listenRet        chan error
+	_listenSync       sync.Mutex
+	_listenWait       sync.WaitGroup
+	_guardOnce        sync.Once
+	_group            *clientGroup
+	_delegate         IServerDelegate
+	_codec            FrameCodec
+	_plainCodec       FrameCodec
+	_legacyCodec      FrameCodec
+	_batchWindow      int64
+	_batchLimit       int
+	_batchDirty       map[uint64]struct{}
+	_batchSync        sync.Mutex
+	_ciphers          []int
+	_minBuild         uint32
+	_sessions         *sessionSet
+	_compressor       Compressor
+	_rss              *RouteSet
+	_topics           *topicSet
+	_timeouts         *DeadlineQueue
+	_authPolicy       int
+	_authenticator    Authenticator
+	_rateLimit        RateLimit
+	_rateLimits       map[string]RateLimit
+	_rateAction       int
+	_admission        *admission
+	_metrics          metrics
+	_rssCtrlID        *util.SnowFlake
+	_authTimeout      int64
+	_handshakeTimeout int64
+	_idleTimeout      int64
+	_drainTimeout     int64
+	_guardInterval    int64
+	_ishutdown        bool
 }
 
 //Control Create a Control
@@ -240,88 +560,204 @@ func (slf *Server) RouteCall(addr, method string, param, ret proto.Message) erro
 	return slf._rss.Call(addr, method, param, ret)
 }
 
-//Listen Start listen
+//Listen Start listen, returns when the listen is completed or failed, it does not block
+//until Shutdown. It can be called for several addresses, tcp://, tls://, ws://, wss://
+//or udp://, the listeners share clients, routes and delegate.
+//tls:// and wss:// terminate tls, address without scheme listens by the socket mode and terminates
+//tls when it is configured
 func (slf *Server) Listen(addr string) error {
-	slf._listenWait.Add(1)
-	if slf._err = slf._listenHandle.Listen(addr); slf._err != nil {
-		slf._listenWait.Done()
-		return slf._err
+	slf._listenSync.Lock()
+	defer slf._listenSync.Unlock()
+
+	mode, secure, addr, err := slf.listenMode(addr)
+	if err != nil {
+		return err
 	}
 
-	slf._listenWait.Wait()
-	return slf._err
+	h, ret := slf._listenHandle, slf._listenRet
+	if slf._listened || mode != slf._mode || secure != (slf._tls != nil) {
+		ret = make(chan error, 1)
+		handler.Spawn(fmt.Sprintf("%s/listen/%d", slf._name, len(slf._listens)+1), func() handler.IService {
+			if h, err = slf.spawnListen(mode, secure, ret); err != nil {
+				return nil
+			}
+			h.Initial()
+			return h
+		})
+
+		if err != nil {
+			return err
+		}
+		slf._listens = append(slf._listens, h)
+	}
+	slf._listened = true
+
+	if err = h.Listen(addr); err != nil {
+		return err
+	}
+
+	return <-ret
 }
 
-//WithCliAuth Set client auth
-func (slf *Server) WithCliAuth(handle uint64, auth int64) error {
-	c := slf._listenHandle.Grap(handle)
-	if c == nil {
-		return errors.New("client unkonw")
+//listenMode split scheme of listen address, returns socket mode and whether tls is terminated
+func (slf *Server) listenMode(addr string) (int, bool, string, error) {
+	i := strings.Index(addr, "://")
+	if i < 0 {
+		return slf._mode, slf._tls != nil, addr, nil
 	}
 
-	c.(*client).WithAuth(auth)
-	slf._listenHandle.Release(c)
-	return nil
+	scheme := addr[:i]
+	addr = addr[i+3:]
+	switch scheme {
+	case "tcp":
+		return TCPNet, false, addr, nil
+	case "ws":
+		return WWSNet, false, addr, nil
+	case "tls", "wss":
+		if slf._tls == nil {
+			return 0, false, "", code.ErrTLSUnconfigured
+		}
+
+		if scheme == "tls" {
+			return TCPNet, true, addr, nil
+		}
+		return WWSNet, true, addr, nil
+	case "udp":
+		return UDPNet, false, addr, nil
+	default:
+		return 0, false, "", code.ErrListenUnsupported
+	}
 }
 
-func (slf *Server) defaultDecode(context actor.Context, params ...interface{}) error {
-	c := params[1].(client)
-	argee, err := slf._delegate.AsyncDecode(params[1].(net.INetClient))
-	if err != nil {
-		return err
+//newListen create a listener of the mode, gateway owned listeners send the result to the channel
+func (slf *Server) newListen(mode int, secure bool, ret chan error) net.INetListener {
+	switch {
+	case mode == UDPNet:
+		return newUDPListen(slf, ret)
+	case secure && mode == TCPNet:
+		return newTLSListen(slf, slf._tls, ret)
+	case secure:
+		return newWSSListen(slf, slf._tls, ret)
+	case mode == TCPNet:
+		return &net.TCPListen{}
+	default:
+		return &net.WSSListen{}
+	}
+}
+
+//spawnListen create a listener actor of the mode, all listeners share the client group.
+//The listen result is sent to the channel, each listener has its own
+func (slf *Server) spawnListen(mode int, secure bool, ret chan error) (*listener.NetListener, error) {
+	return listener.Spawn(
+		listener.WithListener(slf.newListen(mode, secure, ret)),
+		listener.WithAsyncError(func(err error) { slf.asyncError(ret, err) }),
+		listener.WithClientKeepTime(slf._keepTime),
+		listener.WithClientOutChanSize(slf._outCChanSize),
+		listener.WithAsyncComplete(func(sock int32) { slf.asyncComplate(ret, sock) }),
+		listener.WithAsyncAccept(slf.asyncAccept),
+		listener.WithAsyncClosed(slf.asyncClosed),
+		listener.WithClientGroups(slf._group),
+		listener.WithClientDecoder(slf.defaultDecode),
+	)
+}
+
+//WithCliAuth Set client auth level, agreements of higher level are rejected.
+//Level 0 changes an authenticated client back to unauthenticated
+func (slf *Server) WithCliAuth(handle uint64, auth int64) error {
+	c := slf._group.Grap(handle)
+	if c == nil {
+		return code.ErrClientUndefined
 	}
+	defer slf._group.Release(c)
 
-	actor.DefaultSchedulerContext.Send(c.GetPID(), argee)
+	if err := slf.authenticate(c.(*client), auth); err != nil {
+		return err
+	}
+	return slf.issueToken(c.(*client))
+}
 
-	return nil
+func (slf *Server) defaultDecode(context actor.Context, params ...interface{}) error {
+	return slf.decodeClient(params[1].(*client))
 }
 
-func (slf *Server) asyncGuard([]interface{}) {
-	startTime := (time.Now().UnixNano() / int64(time.Millisecond))
-	curreTime := startTime
-	interval := curreTime
-	defer slf._listenWait.Done()
-	for {
-		if slf._ishutdown {
-			break
+//decodeClient decode a frame of client and send agreements to the client actor
+func (slf *Server) decodeClient(c *client) error {
+	argee, err := slf._delegate.AsyncDecode(c)
+	now := time.Now().UnixNano()
+	if err == nil {
+		atomic.StoreInt64(&c._recvTime, now/int64(time.Millisecond))
+		if c.GetState() == StateHandshaking {
+			//delegate without handshake
+			slf.transit(c, StateUnauthenticated)
+		}
+	} else {
+		if err != net.ErrAnalysisProceed {
+			//the stream cannot be resynchronized after a bad frame
+			slf.fault(c, err)
+			c.close()
 		}
+		return err
+	}
 
-		curreTime = (time.Now().UnixNano() / int64(time.Millisecond))
-		interval = curreTime - startTime
-		var c net.INetClient
-		var cs *client
-		clients := slf._listenHandle.GetClients()
-		for _, v := range clients {
-			c = slf._listenHandle.Grap(v)
-			if c == nil {
-				continue
+	if batch, ok := argee.AgreementData.(AgreBatch); ok {
+		for _, v := range batch {
+			dispatch, alive := slf.limitRate(c, v, now)
+			if !alive {
+				break
+			} else if dispatch {
+				actor.DefaultSchedulerContext.Send(c.GetPID(), v)
 			}
+		}
+		return nil
+	}
 
-			cs = c.(*client)
-			if cs._auth > 1 {
-				continue
-			}
+	if dispatch, _ := slf.limitRate(c, argee, now); dispatch {
+		actor.DefaultSchedulerContext.Send(c.GetPID(), argee)
+	}
 
-			if cs._auth == 0 {
-				continue
-			}
+	return nil
+}
 
-			cs._authLastTime -= interval
-			if cs._authLastTime <= 0 {
-				network.OperClose(cs.GetSocket())
-			}
+func (slf *Server) isCipherAllowed(mode int) bool {
+	if len(slf._ciphers) == 0 {
+		return mode == CipherRC4 || mode == CipherAESGCM || mode == CipherChaCha20
+	}
+
+	for _, v := range slf._ciphers {
+		if v == mode {
+			return true
 		}
-		startTime = curreTime
-		time.Sleep(time.Duration(slf._guardInterval) * time.Millisecond)
+	}
+	return false
+}
+
+//rejectAuth answer an agreement under auth level or close the client by auth policy
+func (slf *Server) rejectAuth(c *client, req *AgreMsg) {
+	atomic.AddUint64(&slf._metrics._authRejected, 1)
+	if slf._authPolicy == AuthClose {
+		c.LogError("client %s => %d %s unauthorized, closed", c.GetAddr(), c.GetSocket(), req.Agreement.(string))
+		c.close()
+		return
+	}
 
+	rsp := &AgreementError{Agreement: req.Agreement.(string), Code: ErrorUnauthorized}
+	if err := slf.sendTo(c, rsp); err != nil {
+		c.LogError("reject client %s => %d %s", c.GetAddr(), c.GetSocket(), err.Error())
 	}
 }
 
 func (slf *Server) asyncAccept(c net.INetClient) error {
 	c.(*client)._parent = slf
-	c.(*client)._auth = 1
-	c.(*client)._authLastTime = slf._authTimeout
-	network.OperOpen(c.GetSocket())
+	if err := slf.admit(c.(*client)); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&c.(*client)._auth, 0)
+	c.(*client).reset(time.Now().UnixNano() / int64(time.Millisecond))
+	c.(*client)._limiter = slf.newRateLimiter()
+	slf.schedule(c.(*client))
+	if c.(*client)._conn == nil {
+		network.OperOpen(c.GetSocket())
+	}
 	if slf._delegate != nil {
 		return slf._delegate.AsyncAccept(c)
 	}
@@ -329,25 +765,59 @@ func (slf *Server) asyncAccept(c net.INetClient) error {
 }
 
 func (slf *Server) asyncClosed(h uint64) error {
+	if h == 0 {
+		//handle was taken over by a resumed connection
+		return nil
+	}
+
+	if slf._sessions != nil && slf._sessions.detach(h, time.Now().UnixNano()/int64(time.Millisecond)) {
+		//closed when the grace period is over
+		return nil
+	}
+
+	return slf.closeHandle(h)
+}
+
+//closeHandle release state of a closed client handle
+func (slf *Server) closeHandle(h uint64) error {
+	slf._topics.drop(h)
 	if slf._delegate != nil {
 		return slf._delegate.AsyncClosed(h)
 	}
 	return nil
 }
 
-func (slf *Server) asyncError(err error) {
-	defer slf._listenWait.Done()
-	slf._err = err
+//asyncError send error of a listener to its result channel, errors after listen are dropped
+func (slf *Server) asyncError(ret chan error, err error) {
+	select {
+	case ret <- err:
+	default:
+	}
 }
 
-func (slf *Server) asyncComplate(sock int32) {
-	defer slf._listenWait.Done()
-	slf._err = nil
-	slf._listenWait.Add(1)
-	coroutine.Instance().Go(slf.asyncGuard)
+func (slf *Server) asyncComplate(ret chan error, sock int32) {
+	slf._guardOnce.Do(func() {
+		slf._listenWait.Add(1)
+		coroutine.Instance().Go(slf.asyncGuard)
+		if slf._batchWindow > 0 {
+			slf._listenWait.Add(1)
+			coroutine.Instance().Go(slf.asyncBatch)
+		}
+	})
+
+	select {
+	case ret <- nil:
+	default:
+	}
 }
 
 func (slf *Server) onCtrlConnected(c *rpcc.RPCClient) {
+	if e := c.RegRPC(&deliverCtrl{slf}); e != nil {
+		network.OperClose(c.GetSocket())
+		slf._listenHandle.LogError("RouteSet control register deliver error:%+v", e)
+		return
+	}
+
 	id, _ := slf._rssCtrlID.NextID()
 	r, e := c.CallReturn("regCtrl.SignIn", &service.SignInReq{ClientHandle: uint64(id)})
 	if e != nil {
@@ -367,6 +837,11 @@ func (slf *Server) onCtrlConnected(c *rpcc.RPCClient) {
 func (slf *Server) Shutdown() {
 	slf._ishutdown = true
 	slf._listenWait.Wait()
+	for _, h := range slf._listens {
+		h.Shutdown()
+	}
+	slf._listens = nil
+
 	if slf._listenHandle != nil {
 		slf._listenHandle.Shutdown()
 		slf._listenHandle = nil
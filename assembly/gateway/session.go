@@ -0,0 +1,264 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+//session authenticated client state, it is detached when the connection drops
+//and kept until the grace period is over or a client resumes it with the token.
+//Messages are kept while it is claimed by a resuming client too
+type session struct {
+	_handle  uint64
+	_auth    int64
+	_user    uint64
+	_token   [constResumeTokenByte]byte
+	_expire  int64
+	_claimed bool
+	_pending []interface{}
+}
+
+//sessionSet resumable sessions of server
+type sessionSet struct {
+	_grace   int64
+	_limit   int
+	_handles map[uint64]*session
+	_tokens  map[[constResumeTokenByte]byte]*session
+	_sync    sync.Mutex
+}
+
+func newSessionSet(grace int64, limit int) *sessionSet {
+	return &sessionSet{_grace: grace,
+		_limit:   limit,
+		_handles: make(map[uint64]*session),
+		_tokens:  make(map[[constResumeTokenByte]byte]*session)}
+}
+
+//issue create or refresh the session of handle, returns a new token
+func (slf *sessionSet) issue(h uint64, auth int64, user uint64) ([constResumeTokenByte]byte, error) {
+	var token [constResumeTokenByte]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return token, err
+	}
+
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	s, ok := slf._handles[h]
+	if !ok {
+		s = &session{_handle: h}
+		slf._handles[h] = s
+	} else {
+		delete(slf._tokens, s._token)
+	}
+
+	s._auth = auth
+	s._user = user
+	s._token = token
+	s._expire = 0
+	s._claimed = false
+	slf._tokens[token] = s
+	return token, nil
+}
+
+//remove drop the session of handle
+func (slf *sessionSet) remove(h uint64) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	if s, ok := slf._handles[h]; ok {
+		delete(slf._tokens, s._token)
+		delete(slf._handles, h)
+	}
+}
+
+//detach start the grace period of handle, returns false when handle has no session
+func (slf *sessionSet) detach(h uint64, now int64) bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	s, ok := slf._handles[h]
+	if !ok {
+		return false
+	}
+
+	s._expire = now + slf._grace
+	return true
+}
+
+//push keep a message of detached session, the session expires when the buffer overflows
+func (slf *sessionSet) push(h uint64, msg interface{}, now int64) bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	s, ok := slf._handles[h]
+	if !ok || (!s._claimed && s._expire == 0) || (s._expire != 0 && s._expire <= now) {
+		return false
+	}
+
+	if len(s._pending) >= slf._limit {
+		//missed messages cannot be replayed, client must sign in again
+		s._expire = now
+		s._pending = nil
+		return false
+	}
+
+	s._pending = append(s._pending, msg)
+	return true
+}
+
+//claim take the session of token for a resuming client, returns its handle.
+//The token is used once, messages are kept until the session is attached
+func (slf *sessionSet) claim(token [constResumeTokenByte]byte, now int64) (uint64, bool) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	s, ok := slf._tokens[token]
+	if !ok || (s._expire != 0 && s._expire <= now) {
+		return 0, false
+	}
+
+	delete(slf._tokens, token)
+	s._claimed = true
+	return s._handle, true
+}
+
+//attach end the claim of the session, returns the session state and messages missed
+func (slf *sessionSet) attach(h uint64, now int64) (session, []interface{}, bool) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	s, ok := slf._handles[h]
+	if !ok || !s._claimed || (s._expire != 0 && s._expire <= now) {
+		return session{}, nil, false
+	}
+
+	pending := s._pending
+	s._pending = nil
+	s._expire = 0
+	s._claimed = false
+	return *s, pending, true
+}
+
+//expired remove sessions of grace period over, returns their handles
+func (slf *sessionSet) expired(now int64) []uint64 {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	var result []uint64
+	for h, s := range slf._handles {
+		if s._expire != 0 && s._expire <= now {
+			delete(slf._tokens, s._token)
+			delete(slf._handles, h)
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+//issueToken send a new resume token to an authenticated client
+func (slf *Server) issueToken(c *client) error {
+	if slf._sessions == nil {
+		return nil
+	}
+
+	if c.GetState() != StateAuthenticated {
+		slf._sessions.remove(c.GetID())
+		return nil
+	}
+
+	token, err := slf._sessions.issue(c.GetID(), atomic.LoadInt64(&c._auth), atomic.LoadUint64(&c._userID))
+	if err != nil {
+		return err
+	}
+
+	return slf.sendTo(c, &ResumeToken{Token: token[:], Grace: slf._sessions._grace})
+}
+
+//claim take the session of a resume token, returns its handle
+func (slf *Server) claim(token [constResumeTokenByte]byte) (uint64, bool) {
+	if slf._sessions == nil {
+		return 0, false
+	}
+	return slf._sessions.claim(token, time.Now().UnixNano()/int64(time.Millisecond))
+}
+
+//resume bind the client to the claimed session of handle, a connection still holding the session
+//is closed. The session encryption of client must be set, messages missed are sent before
+//messages pushed after the session is attached
+func (slf *Server) resume(c *client, h uint64) error {
+	if old := slf._group.Grap(h); old != nil {
+		if old != c {
+			old.(*client).close()
+		}
+		slf._group.Release(old)
+	}
+
+	slf._timeouts.Cancel(c.GetID())
+	slf._group.Rebind(c, h)
+
+	//messages pushed until attached are kept by the session, later ones wait for the send lock
+	c._sendSync.Lock()
+	s, pending, ok := slf._sessions.attach(h, time.Now().UnixNano()/int64(time.Millisecond))
+	var err error
+	for _, msg := range pending {
+		if err = slf.send(c, msg); err != nil {
+			break
+		}
+	}
+	c._sendSync.Unlock()
+
+	if !ok {
+		return code.ErrSessionExpired
+	} else if err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&c._auth, s._auth)
+	atomic.StoreUint64(&c._userID, s._user)
+	if err = slf.transit(c, StateAuthenticated); err != nil {
+		//closing the client detaches the session again
+		return err
+	}
+	return slf.issueToken(c)
+}
+
+//push send a message to the client of handle, the message is kept when the client is detached
+func (slf *Server) push(h uint64, msg interface{}) error {
+	c := slf._group.Grap(h)
+	if c == nil || c.(*client).GetState() == StateHandshaking {
+		//a resuming client is handshaking until its session is attached
+		if slf._sessions != nil && slf._sessions.push(h, msg, time.Now().UnixNano()/int64(time.Millisecond)) {
+			if c != nil {
+				slf._group.Release(c)
+			}
+			return nil
+		}
+
+		if c == nil {
+			return code.ErrClientUndefined
+		}
+	}
+	defer slf._group.Release(c)
+
+	if !c.(*client).isReady() {
+		return code.ErrClientHandshaking
+	}
+
+	return slf.sendTo(c.(*client), msg)
+}
+
+//expireSessions close detached sessions of grace period over
+func (slf *Server) expireSessions(now int64) {
+	if slf._sessions == nil {
+		return
+	}
+
+	for _, h := range slf._sessions.expired(now) {
+		slf.closeHandle(h)
+	}
+}
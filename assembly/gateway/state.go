@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+//SessionState client session lifecycle state
+type SessionState int32
+
+const (
+	//StateHandshaking waiting for the client hello
+	StateHandshaking SessionState = iota
+	//StateUnauthenticated handshake completed, waiting for authentication
+	StateUnauthenticated
+	//StateAuthenticated authenticated, agreements are checked by auth level
+	StateAuthenticated
+	//StateDraining agreements are dropped, closed when the drain time out
+	StateDraining
+	//StateClosed connection closed
+	StateClosed
+)
+
+var stateNames = [...]string{"Handshaking", "Unauthenticated", "Authenticated", "Draining", "Closed"}
+
+//String doc
+//@Summary Returns state name
+func (slf SessionState) String() string {
+	if slf < StateHandshaking || slf > StateClosed {
+		return "Unknown"
+	}
+	return stateNames[slf]
+}
+
+//CanTransit doc
+//@Summary Returns true when the state can change to the state,
+//         authenticated to authenticated is a auth level change
+//@Param  next state
+func (slf SessionState) CanTransit(to SessionState) bool {
+	switch slf {
+	case StateHandshaking:
+		return to == StateUnauthenticated || to == StateAuthenticated || to == StateClosed
+	case StateUnauthenticated:
+		return to == StateAuthenticated || to == StateDraining || to == StateClosed
+	case StateAuthenticated:
+		return to == StateUnauthenticated || to == StateAuthenticated || to == StateDraining || to == StateClosed
+	case StateDraining:
+		return to == StateClosed
+	}
+	return false
+}
+
+//GetState doc
+//@Summary Returns session state
+func (slf *client) GetState() SessionState {
+	return SessionState(atomic.LoadInt32(&slf._state))
+}
+
+//reset start a new session of client
+func (slf *client) reset(now int64) {
+	atomic.StoreInt32(&slf._state, int32(StateHandshaking))
+	atomic.StoreInt64(&slf._stateTime, now)
+	atomic.StoreInt64(&slf._recvTime, now)
+}
+
+//transit change session state, returns previous state and false when the transition is invalid
+func (slf *client) transit(to SessionState, now int64) (SessionState, bool) {
+	for {
+		from := slf.GetState()
+		if !from.CanTransit(to) {
+			return from, false
+		}
+
+		if atomic.CompareAndSwapInt32(&slf._state, int32(from), int32(to)) {
+			atomic.StoreInt64(&slf._stateTime, now)
+			return from, true
+		}
+	}
+}
+
+//transit change session state of client and notify the delegate
+func (slf *Server) transit(c *client, to SessionState) error {
+	from, ok := c.transit(to, time.Now().UnixNano()/int64(time.Millisecond))
+	if !ok {
+		return code.ErrStateTransition
+	}
+
+	slf.schedule(c)
+	if slf._delegate != nil {
+		slf._delegate.AsyncState(c, from, to)
+	}
+	return nil
+}
+
+//authenticate set auth level of client, zero level is unauthenticated
+func (slf *Server) authenticate(c *client, auth int64) error {
+	to := StateAuthenticated
+	if auth <= 0 {
+		to, auth = StateUnauthenticated, 0
+	}
+
+	if c.GetState() == StateHandshaking {
+		return code.ErrClientHandshaking
+	}
+
+	prev := atomic.SwapInt64(&c._auth, auth)
+	if err := slf.transit(c, to); err != nil {
+		atomic.StoreInt64(&c._auth, prev)
+		return err
+	}
+	return nil
+}
+
+//isAllowed returns true when the client can send the agreement, auth without
+//minimum level accepts every authenticated level
+func (slf *Server) isAllowed(c *client, agree *DefaultAgreement) bool {
+	if !agree.Auth && agree.MinAuth <= 0 {
+		return true
+	}
+	return c.GetState() == StateAuthenticated && atomic.LoadInt64(&c._auth) >= agree.MinAuth
+}
+
+//Drain doc
+//@Summary stop dispatching agreements of client, it is closed when the drain time out.
+//         Messages sent to client still go out
+//@Param  client handle
+//@Return error
+func (slf *Server) Drain(handle uint64) error {
+	c := slf._group.Grap(handle)
+	if c == nil {
+		return code.ErrClientUndefined
+	}
+	defer slf._group.Release(c)
+
+	if err := slf.transit(c.(*client), StateDraining); err != nil {
+		return err
+	}
+
+	if slf._batchWindow > 0 {
+		return slf.flushBatch(c.(*client))
+	}
+	return nil
+}
+
+//GetState doc
+//@Summary Returns session state of client
+//@Param  client handle
+//@Return SessionState
+//@Return error
+func (slf *Server) GetState(handle uint64) (SessionState, error) {
+	c := slf._group.Grap(handle)
+	if c == nil {
+		return StateClosed, code.ErrClientUndefined
+	}
+	defer slf._group.Release(c)
+	return c.(*client).GetState(), nil
+}
@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type deadline struct {
+	_handle uint64
+	_when   int64
+	_index  int
+}
+
+type deadlineHeap []*deadline
+
+func (slf deadlineHeap) Len() int { return len(slf) }
+
+func (slf deadlineHeap) Less(i, j int) bool { return slf[i]._when < slf[j]._when }
+
+func (slf deadlineHeap) Swap(i, j int) {
+	slf[i], slf[j] = slf[j], slf[i]
+	slf[i]._index = i
+	slf[j]._index = j
+}
+
+func (slf *deadlineHeap) Push(x interface{}) {
+	d := x.(*deadline)
+	d._index = len(*slf)
+	*slf = append(*slf, d)
+}
+
+func (slf *deadlineHeap) Pop() interface{} {
+	old := *slf
+	n := len(old)
+	d := old[n-1]
+	old[n-1] = nil
+	*slf = old[:n-1]
+	return d
+}
+
+//DeadlineQueue doc
+//@Summary handle deadlines ordered by time, a handle has one deadline at most.
+//         Schedule and Cancel are O(log n), Expired is O(k log n) of k expired handles
+type DeadlineQueue struct {
+	_heap  deadlineHeap
+	_items map[uint64]*deadline
+	_sync  sync.Mutex
+}
+
+//NewDeadlineQueue doc
+//@Summary Returns a empty deadline queue
+func NewDeadlineQueue() *DeadlineQueue {
+	return &DeadlineQueue{_items: make(map[uint64]*deadline)}
+}
+
+//Schedule doc
+//@Summary set deadline of handle, the previous deadline is replaced
+//@Param  handle
+//@Param  deadline in millisecond
+func (slf *DeadlineQueue) Schedule(h uint64, when int64) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	if d, ok := slf._items[h]; ok {
+		d._when = when
+		heap.Fix(&slf._heap, d._index)
+		return
+	}
+
+	d := &deadline{_handle: h, _when: when}
+	slf._items[h] = d
+	heap.Push(&slf._heap, d)
+}
+
+//Cancel doc
+//@Summary remove deadline of handle
+//@Param  handle
+func (slf *DeadlineQueue) Cancel(h uint64) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	if d, ok := slf._items[h]; ok {
+		heap.Remove(&slf._heap, d._index)
+		delete(slf._items, h)
+	}
+}
+
+//Expired doc
+//@Summary remove deadlines not later than now
+//@Param  now in millisecond
+//@Return handles expired
+func (slf *DeadlineQueue) Expired(now int64) []uint64 {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	var result []uint64
+	for len(slf._heap) > 0 && slf._heap[0]._when <= now {
+		d := heap.Pop(&slf._heap).(*deadline)
+		delete(slf._items, d._handle)
+		result = append(result, d._handle)
+	}
+	return result
+}
+
+//Len doc
+//@Summary Returns number of deadlines
+func (slf *DeadlineQueue) Len() int {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return len(slf._heap)
+}
+
+//deadlineOf returns time out deadline of client state, false when the state never times out
+func (slf *Server) deadlineOf(c *client) (int64, bool) {
+	var last, timeout int64
+	switch c.GetState() {
+	case StateHandshaking:
+		last, timeout = atomic.LoadInt64(&c._stateTime), slf._handshakeTimeout
+	case StateUnauthenticated:
+		last, timeout = atomic.LoadInt64(&c._stateTime), slf._authTimeout
+	case StateAuthenticated:
+		last, timeout = atomic.LoadInt64(&c._recvTime), slf._idleTimeout
+	case StateDraining:
+		last, timeout = atomic.LoadInt64(&c._stateTime), slf._drainTimeout
+	}
+
+	if timeout <= 0 {
+		return 0, false
+	}
+	return last + timeout, true
+}
+
+//schedule set the time out of client state
+func (slf *Server) schedule(c *client) {
+	if when, ok := slf.deadlineOf(c); ok {
+		slf._timeouts.Schedule(c.GetID(), when)
+		return
+	}
+	slf._timeouts.Cancel(c.GetID())
+}
+
+//expireClients close clients of deadline passed, idle clients received
+//frames since scheduled are scheduled again
+func (slf *Server) expireClients(now int64) {
+	for _, h := range slf._timeouts.Expired(now) {
+		c := slf._group.Grap(h)
+		if c == nil {
+			continue
+		}
+
+		cs := c.(*client)
+		when, ok := slf.deadlineOf(cs)
+		if ok && when <= now {
+			cs.close()
+		} else if ok {
+			slf._timeouts.Schedule(h, when)
+		}
+		slf._group.Release(c)
+	}
+}
+
+func (slf *Server) asyncGuard([]interface{}) {
+	defer slf._listenWait.Done()
+	for {
+		if slf._ishutdown {
+			break
+		}
+
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		slf.expireClients(now)
+		slf.expireSessions(now)
+		slf._admission.expire(now)
+		time.Sleep(time.Duration(slf._guardInterval) * time.Millisecond)
+	}
+}
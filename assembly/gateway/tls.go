@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	stdnet "net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+const (
+	//pending websocket connections not accepted
+	constWSBacklog = 128
+)
+
+var wsUpgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+func newTLSListen(srv *Server, config *tls.Config, ret chan error) *ConnListen {
+	return &ConnListen{_parent: srv, _ret: ret, _listen: func(addr string, ccmax int) (stdnet.Listener, error) {
+		l, err := listenTCP(addr, srv._keepTime)
+		if err != nil {
+			return nil, err
+		}
+		return tls.NewListener(l, config), nil
+	}}
+}
+
+func newWSSListen(srv *Server, config *tls.Config, ret chan error) *ConnListen {
+	return &ConnListen{_parent: srv, _ret: ret, _listen: func(addr string, ccmax int) (stdnet.Listener, error) {
+		return listenWebSocket(addr, config, srv._keepTime)
+	}}
+}
+
+//listenTCP listen tcp, accepted connections send keep alive probes at the interval in milliseconds
+func listenTCP(addr string, keepTime int) (stdnet.Listener, error) {
+	lc := &stdnet.ListenConfig{KeepAlive: time.Duration(keepTime) * time.Millisecond}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+//ListenWebSocket doc
+//@Summary listen websocket connections, binary messages are read as a byte stream
+//@Param  address host:port or host:port/path, without path every path is accepted
+//@Param  tls config, nil is plain websocket
+//@Return net.Listener
+//@Return error
+func ListenWebSocket(addr string, config *tls.Config) (stdnet.Listener, error) {
+	return listenWebSocket(addr, config, 0)
+}
+
+func listenWebSocket(addr string, config *tls.Config, keepTime int) (stdnet.Listener, error) {
+	path := "/"
+	if i := strings.Index(addr, "/"); i >= 0 {
+		addr, path = addr[:i], addr[i:]
+	}
+
+	l, err := listenTCP(addr, keepTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if config != nil {
+		l = tls.NewListener(l, config)
+	}
+
+	ws := &wsListen{_l: l,
+		_accept: make(chan stdnet.Conn, constWSBacklog),
+		_closed: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.Handle(path, ws)
+	ws._srv = &http.Server{Handler: mux}
+	go ws._srv.Serve(l)
+	return ws, nil
+}
+
+//wsListen websocket listener, implements net.Listener
+type wsListen struct {
+	_l      stdnet.Listener
+	_srv    *http.Server
+	_accept chan stdnet.Conn
+	_closed chan struct{}
+	_once   sync.Once
+}
+
+func (slf *wsListen) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	select {
+	case slf._accept <- &wsConn{_c: c}:
+	case <-slf._closed:
+		c.Close()
+	}
+}
+
+func (slf *wsListen) Accept() (stdnet.Conn, error) {
+	select {
+	case c := <-slf._accept:
+		return c, nil
+	case <-slf._closed:
+		return nil, code.ErrConnectClosed
+	}
+}
+
+func (slf *wsListen) Close() error {
+	slf._once.Do(func() {
+		close(slf._closed)
+		slf._srv.Close()
+	})
+	return nil
+}
+
+func (slf *wsListen) Addr() stdnet.Addr {
+	return slf._l.Addr()
+}
+
+//wsConn websocket connection, implements net.Conn
+type wsConn struct {
+	_c    *websocket.Conn
+	_r    io.Reader
+	_sync sync.Mutex
+}
+
+func (slf *wsConn) Read(b []byte) (int, error) {
+	for {
+		if slf._r == nil {
+			_, r, err := slf._c.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			slf._r = r
+		}
+
+		n, err := slf._r.Read(b)
+		if err == io.EOF {
+			slf._r = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (slf *wsConn) Write(b []byte) (int, error) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	if err := slf._c.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (slf *wsConn) Close() error {
+	return slf._c.Close()
+}
+
+func (slf *wsConn) LocalAddr() stdnet.Addr {
+	return slf._c.LocalAddr()
+}
+
+func (slf *wsConn) RemoteAddr() stdnet.Addr {
+	return slf._c.RemoteAddr()
+}
+
+func (slf *wsConn) SetDeadline(t time.Time) error {
+	if err := slf._c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return slf.SetWriteDeadline(t)
+}
+
+func (slf *wsConn) SetReadDeadline(t time.Time) error {
+	return slf._c.SetReadDeadline(t)
+}
+
+//SetWriteDeadline the deadline of websocket applies to next write, the connection
+//deadline applies to the write in progress
+func (slf *wsConn) SetWriteDeadline(t time.Time) error {
+	if err := slf._c.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return slf._c.UnderlyingConn().SetWriteDeadline(t)
+}
@@ -0,0 +1,125 @@
+package gateway
+
+import (
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+)
+
+//topicSet client subscriptions by topic name
+type topicSet struct {
+	_topics  map[string]map[uint64]struct{}
+	_handles map[uint64]map[string]struct{}
+	_sync    sync.RWMutex
+}
+
+func newTopicSet() *topicSet {
+	return &topicSet{_topics: make(map[string]map[uint64]struct{}),
+		_handles: make(map[uint64]map[string]struct{})}
+}
+
+func (slf *topicSet) subscribe(h uint64, topic string) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	subscribers, ok := slf._topics[topic]
+	if !ok {
+		subscribers = make(map[uint64]struct{})
+		slf._topics[topic] = subscribers
+	}
+	subscribers[h] = struct{}{}
+
+	topics, ok := slf._handles[h]
+	if !ok {
+		topics = make(map[string]struct{})
+		slf._handles[h] = topics
+	}
+	topics[topic] = struct{}{}
+}
+
+func (slf *topicSet) unsubscribe(h uint64, topic string) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	slf.remove(h, topic)
+	if topics, ok := slf._handles[h]; ok {
+		delete(topics, topic)
+		if len(topics) == 0 {
+			delete(slf._handles, h)
+		}
+	}
+}
+
+//drop remove all subscriptions of handle
+func (slf *topicSet) drop(h uint64) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	for topic := range slf._handles[h] {
+		slf.remove(h, topic)
+	}
+	delete(slf._handles, h)
+}
+
+func (slf *topicSet) remove(h uint64, topic string) {
+	if subscribers, ok := slf._topics[topic]; ok {
+		delete(subscribers, h)
+		if len(subscribers) == 0 {
+			delete(slf._topics, topic)
+		}
+	}
+}
+
+//subscribers returns handles of topic
+func (slf *topicSet) subscribers(topic string) []uint64 {
+	slf._sync.RLock()
+	defer slf._sync.RUnlock()
+
+	subscribers := slf._topics[topic]
+	result := make([]uint64, 0, len(subscribers))
+	for h := range subscribers {
+		result = append(result, h)
+	}
+	return result
+}
+
+//Subscribe doc
+//@Summary subscribe a client to topic, the subscription is removed when the client is closed
+//@Param  client handle
+//@Param  topic name
+//@Return error, code.ErrClientUndefined when the client is unknown or closed
+func (slf *Server) Subscribe(handle uint64, topic string) error {
+	c := slf._group.Grap(handle)
+	if c == nil {
+		return code.ErrClientUndefined
+	}
+	slf._group.Release(c)
+
+	slf._topics.subscribe(handle, topic)
+	//a client closed meanwhile has dropped its subscriptions already
+	if c = slf._group.Grap(handle); c == nil {
+		slf._topics.unsubscribe(handle, topic)
+		return code.ErrClientUndefined
+	}
+	slf._group.Release(c)
+	return nil
+}
+
+//Unsubscribe doc
+//@Summary unsubscribe a client from topic
+//@Param  client handle
+//@Param  topic name
+func (slf *Server) Unsubscribe(handle uint64, topic string) {
+	slf._topics.unsubscribe(handle, topic)
+}
+
+//Publish doc
+//@Summary push a message to subscribers of topic, the message is marshaled once
+//@Param  topic name
+//@Param  message
+//@Return handles of subscribers closed or failed
+//@Return error of marshal
+func (slf *Server) Publish(topic string, msg proto.Message) ([]uint64, error) {
+	return slf.Multicast(slf._topics.subscribers(topic), msg)
+}
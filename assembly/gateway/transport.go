@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	stdnet "net"
+	"sync/atomic"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/rudp"
+	"github.com/yamakiller/magicNet/engine/actor"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+const (
+	//gateway owned connection read size
+	constConnReadByte = 4096
+	//gateway owned connection writes queued data in milliseconds when it is closed
+	constConnLinger = 1000
+)
+
+//gateway owned connections use negative socket ids, they never collide with network engine sockets
+var connSocket int32
+
+//ConnListen doc
+//@Summary gateway owned listener, implements net.INetListener. Connections are
+//         served by the gateway instead of the network engine and share the client group,
+//         handshake and frame codec with the other modes
+type ConnListen struct {
+	_parent *Server
+	_listen func(addr string, ccmax int) (stdnet.Listener, error)
+	_ret    chan error
+	_l      stdnet.Listener
+}
+
+func newUDPListen(srv *Server, ret chan error) *ConnListen {
+	return &ConnListen{_parent: srv, _ret: ret, _listen: func(addr string, ccmax int) (stdnet.Listener, error) {
+		l, err := rudp.Listen(addr, ccmax)
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
+	}}
+}
+
+//Listen doc
+//@Summary start listen, the listen is completed synchronously
+//@Param  listener actor context
+//@Param  listen address
+//@Param  maximum connections
+//@Return error
+func (slf *ConnListen) Listen(context actor.Context, addr string, ccmax int) error {
+	l, err := slf._listen(addr, ccmax)
+	if err != nil {
+		return err
+	}
+
+	slf._l = l
+	go slf.asyncAccept()
+	slf._parent.asyncComplate(slf._ret, slf.GetSocket())
+	return nil
+}
+
+//GetSocket doc
+//@Summary Returns 0, the listener is not a network engine socket
+func (slf *ConnListen) GetSocket() int32 {
+	return 0
+}
+
+//GetAddr doc
+//@Summary Returns listen address
+func (slf *ConnListen) GetAddr() string {
+	if slf._l == nil {
+		return ""
+	}
+	return slf._l.Addr().String()
+}
+
+//Close doc
+//@Summary stop listen
+func (slf *ConnListen) Close() {
+	if slf._l != nil {
+		slf._l.Close()
+	}
+}
+
+func (slf *ConnListen) asyncAccept() {
+	for {
+		conn, err := slf._l.Accept()
+		if err != nil {
+			return
+		}
+
+		go slf._parent.serveConn(conn)
+	}
+}
+
+//serveConn read a gateway owned connection until it is closed
+func (slf *Server) serveConn(conn stdnet.Conn) {
+	c, err := slf.acceptConn(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer slf.closedConn(c)
+
+	tmpByte := make([]byte, constConnReadByte)
+	for {
+		n, err := conn.Read(tmpByte)
+		if err != nil {
+			return
+		}
+
+		if _, err = c.WriteBuffer(tmpByte[:n]); err != nil {
+			c.LogError("client %s => %d %s", c.GetAddr(), c.GetSocket(), err.Error())
+			return
+		}
+
+		for err == nil {
+			err = slf.decodeClient(c)
+		}
+
+		if err != net.ErrAnalysisProceed {
+			return
+		}
+	}
+}
+
+//acceptConn allocate a client of the connection
+func (slf *Server) acceptConn(conn stdnet.Conn) (*client, error) {
+	if slf._group.Cap() > 0 && slf._group.Size() >= slf._group.Cap() {
+		return nil, code.ErrClientFull
+	}
+
+	c := slf._group.Allocer().New().(*client)
+	c._conn = conn
+	c._connSocket = atomic.AddInt32(&connSocket, -1)
+	if _, err := slf._group.Occupy(c); err != nil {
+		slf._group.Allocer().Delete(c)
+		return nil, err
+	}
+
+	c._out = make(chan []byte, slf._outCChanSize)
+	c._closing = make(chan struct{}, 1)
+	go writeConn(conn, c._out, c._closing)
+
+	if err := slf.asyncAccept(c); err != nil {
+		slf.closedConn(c)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+//writeConn write queued data of a gateway owned connection, slow connections block here
+//instead of the sender. The connection is closed after data queued before closing
+func writeConn(conn stdnet.Conn, out chan []byte, closing chan struct{}) {
+	defer conn.Close()
+	for {
+		select {
+		case d := <-out:
+			if _, err := conn.Write(d); err != nil {
+				return
+			}
+		case <-closing:
+			for {
+				select {
+				case d := <-out:
+					if _, err := conn.Write(d); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+//notifyConn signal the writer without blocking
+func notifyConn(event chan struct{}) {
+	select {
+	case event <- struct{}{}:
+	default:
+	}
+}
+
+//closedConn release the client of a closed connection, the connection is closed
+//by the writer after queued data
+func (slf *Server) closedConn(c *client) {
+	c.close()
+	h := slf._group.EraseClient(c)
+	unsent := c.takeBatch()
+	slf._group.Release(c)
+	slf.asyncClosed(h)
+
+	//queued messages are kept by the detached session
+	for _, msg := range unsent {
+		if slf.push(h, msg) != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package gwclient
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicLibs/encryption/dh64"
+	"github.com/yamakiller/magicNet/handler/encryption"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+const (
+	constReadByte = 4096
+)
+
+//Options Gateway Client Options
+type Options struct {
+	Build         uint32
+	Cipher        int
+	Encrypt       bool
+	Compressor    gateway.Compressor
+	CompressLimit int
+	FragmentSize  int
+	MaxMessage    int
+	FrameMode     int
+	Registry      *gateway.MessageRegistry
+	BufferCap     int
+	Timeout       int64
+	RecvChanSize  int
+	OnReceive     func(proto.Message)
+	TLS           *tls.Config
+	ResumeToken   []byte
+}
+
+//Option Gateway Client Option function
+type Option func(*Options) error
+
+//WithBuild Set client build sent in handshake
+func WithBuild(build uint32) Option {
+	return func(o *Options) error {
+		o.Build = build
+		return nil
+	}
+}
+
+//WithCipher Set session cipher requested in handshake, default CipherAESGCM
+func WithCipher(mode int) Option {
+	return func(o *Options) error {
+		if mode != gateway.CipherRC4 && mode != gateway.CipherAESGCM && mode != gateway.CipherChaCha20 {
+			return code.ErrCipherUnsupported
+		}
+		o.Cipher = mode
+		return nil
+	}
+}
+
+//WithEncrypt Set whether frames are encrypted, must match DefaultDelegate.Encrypt of gateway
+func WithEncrypt(encrypt bool) Option {
+	return func(o *Options) error {
+		o.Encrypt = encrypt
+		return nil
+	}
+}
+
+//WithCompression Set supported frame compressor,
+//data length greater than the threshold will be compressed
+func WithCompression(c gateway.Compressor, threshold int) Option {
+	return func(o *Options) error {
+		o.Compressor = c
+		o.CompressLimit = threshold
+		return nil
+	}
+}
+
+//WithFragment Set fragment data length and maximum message length of reassembled,
+//fragment requires the maximum message length
+func WithFragment(size, maxMessage int) Option {
+	return func(o *Options) error {
+		if maxMessage <= 0 {
+			return code.ErrFragmentUnsupported
+		}
+		o.FragmentSize = size
+		o.MaxMessage = maxMessage
+		return nil
+	}
+}
+
+//WithRegistry Set message id registry, frames carry message id
+func WithRegistry(registry *gateway.MessageRegistry) Option {
+	return func(o *Options) error {
+		o.FrameMode = gateway.IDFrame
+		o.Registry = registry
+		return nil
+	}
+}
+
+//WithBufferCap Set receive buffer limit
+func WithBufferCap(cap int) Option {
+	return func(o *Options) error {
+		o.BufferCap = cap
+		return nil
+	}
+}
+
+//WithTimeout Set dial and handshake time out in milliseconds
+func WithTimeout(tm int64) Option {
+	return func(o *Options) error {
+		o.Timeout = tm
+		return nil
+	}
+}
+
+//WithRecvChanSize Set receive channel buffer size
+func WithRecvChanSize(ch int) Option {
+	return func(o *Options) error {
+		o.RecvChanSize = ch
+		return nil
+	}
+}
+
+//WithReceive Set receive callback, called in the read goroutine,
+//messages are not delivered to the receive channel
+func WithReceive(f func(proto.Message)) Option {
+	return func(o *Options) error {
+		o.OnReceive = f
+		return nil
+	}
+}
+
+//WithTLS Set tls config of tcp or wss connection
+func WithTLS(config *tls.Config) Option {
+	return func(o *Options) error {
+		o.TLS = config
+		return nil
+	}
+}
+
+//WithResume Set resume token of a dropped session, see Client.ResumeToken
+func WithResume(token []byte) Option {
+	return func(o *Options) error {
+		o.ResumeToken = token
+		return nil
+	}
+}
+
+var (
+	defaultOption = Options{Cipher: gateway.CipherAESGCM,
+		Encrypt:      true,
+		BufferCap:    64 * 1024,
+		Timeout:      10 * 1000,
+		RecvChanSize: 256,
+	}
+)
+
+//DialError doc
+//@Summary connecting to gateway failed, tls handshake of tcp is a part of connecting,
+//         udp is connecting until the gateway answers the client hello
+//@Member  gateway address
+//@Member  transport error
+type DialError struct {
+	Addr string
+	Err  error
+}
+
+func (slf *DialError) Error() string {
+	return fmt.Sprintf("Dial %s %s", slf.Addr, slf.Err.Error())
+}
+
+//Unwrap doc
+//@Summary Returns transport error
+func (slf *DialError) Unwrap() error {
+	return slf.Err
+}
+
+//HandshakeError doc
+//@Summary gateway rejected the handshake or the handshake failed after connected
+//@Member  reason code of rejected, see gateway.HandshakeVersion...
+//@Member  error of failed handshake, nil when the gateway rejected it
+type HandshakeError struct {
+	Code uint8
+	Err  error
+}
+
+func (slf *HandshakeError) Error() string {
+	if slf.Err != nil {
+		return fmt.Sprintf("Handshake failed %s", slf.Err.Error())
+	}
+	return fmt.Sprintf("Handshake rejected code:%d", slf.Code)
+}
+
+//Unwrap doc
+//@Summary Returns error of failed handshake
+func (slf *HandshakeError) Unwrap() error {
+	return slf.Err
+}
+
+//Dial doc
+//@Summary connect to gateway and complete handshake
+//@Param  address, tcp://host:port, host:port, tls://host:port, udp://host:port, ws://host:port/path or wss://host:port/path
+//@Param  options
+//@Return *Client
+//@Return error, *DialError when connecting failed, *HandshakeError when the handshake failed
+func Dial(addr string, options ...Option) (*Client, error) {
+	opts := defaultOption
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.FrameMode == gateway.IDFrame && opts.Registry == nil {
+		return nil, code.ErrMessageUnregistered
+	}
+
+	timeout := time.Duration(opts.Timeout) * time.Millisecond
+	c, err := dial(addr, timeout, opts.TLS)
+	if err != nil {
+		return nil, &DialError{Addr: addr, Err: err}
+	}
+
+	cli := &Client{_opts: opts,
+		_conn:   c,
+		_buffer: &buffer{_cap: opts.BufferCap},
+		_closed: make(chan struct{})}
+
+	if err = cli.handshake(timeout, strings.HasPrefix(addr, "udp://")); err != nil {
+		switch e := err.(type) {
+		case *DialError:
+			//the gateway never answered, the client hello is not lingered
+			c.SetDeadline(time.Now())
+			e.Addr = addr
+		case *HandshakeError:
+		default:
+			err = &HandshakeError{Err: err}
+		}
+		c.Close()
+		return nil, err
+	}
+
+	if opts.OnReceive == nil {
+		cli._recv = make(chan proto.Message, opts.RecvChanSize)
+	}
+
+	cli._wait.Add(1)
+	go cli.asyncRead()
+
+	return cli, nil
+}
+
+//Client doc
+//@Summary gateway protocol client
+type Client struct {
+	_opts     Options
+	_conn     conn
+	_buffer   *buffer
+	_encrypt  encryption.INetEncryption
+	_codec    *gateway.DefaultFrameCodec
+	_version  uint16
+	_resumed  bool
+	_token    []byte
+	_recv     chan proto.Message
+	_sendSync sync.Mutex
+	_err      error
+	_errSync  sync.Mutex
+	_closed   chan struct{}
+	_once     sync.Once
+	_wait     sync.WaitGroup
+}
+
+//GetVersion doc
+//@Summary Returns protocol version negotiated
+func (slf *Client) GetVersion() uint16 {
+	return slf._version
+}
+
+//Resumed doc
+//@Summary Returns the gateway resumed the session of resume token
+func (slf *Client) Resumed() bool {
+	return slf._resumed
+}
+
+//ResumeToken doc
+//@Summary Returns the last resume token received, dial with it by WithResume after the
+//         connection drops to get the session back
+func (slf *Client) ResumeToken() []byte {
+	slf._errSync.Lock()
+	defer slf._errSync.Unlock()
+	return slf._token
+}
+
+//Recv doc
+//@Summary Returns receive channel, it is closed when the connection is closed,
+//         nil when receive callback is set
+func (slf *Client) Recv() <-chan proto.Message {
+	return slf._recv
+}
+
+//Err doc
+//@Summary Returns the error that closed the connection
+func (slf *Client) Err() error {
+	slf._errSync.Lock()
+	defer slf._errSync.Unlock()
+	return slf._err
+}
+
+//Send doc
+//@Summary encode a message and send to gateway
+//@Param  message
+//@Return error
+func (slf *Client) Send(msg proto.Message) error {
+	name, err := slf.getMessageName(msg)
+	if err != nil {
+		return err
+	}
+
+	slf._sendSync.Lock()
+	defer slf._sendSync.Unlock()
+
+	d, err := slf._codec.EncodeMessage(slf.getEncrypt(), name, msg)
+	if err != nil {
+		return err
+	}
+
+	return slf._conn.Write(d)
+}
+
+//Close doc
+//@Summary close connection and wait read goroutine exit,
+//         cannot be called in receive callback
+func (slf *Client) Close() error {
+	var err error
+	slf._once.Do(func() {
+		close(slf._closed)
+		err = slf._conn.Close()
+	})
+	slf._wait.Wait()
+	return err
+}
+
+//handshake send client hello and install session encryptor of server hello,
+//reliable udp is not connected until the gateway answers
+func (slf *Client) handshake(timeout time.Duration, udp bool) error {
+	if timeout > 0 {
+		slf._conn.SetDeadline(time.Now().Add(timeout))
+		defer slf._conn.SetDeadline(time.Time{})
+	}
+
+	kex := &dh64.KeyExchange{P: dh64.DefaultP, G: dh64.DefaultG}
+	privateKey, publicKey := kex.KeyPair()
+
+	hello := &gateway.ClientHello{Version: gateway.ProtocolVersion,
+		Build:     slf._opts.Build,
+		Cipher:    uint8(slf._opts.Cipher),
+		PublicKey: publicKey}
+	if slf._opts.Compressor != nil {
+		hello.Compress = gateway.CompressMask(slf._opts.Compressor.ID())
+	}
+
+	if len(slf._opts.ResumeToken) == len(hello.Token) {
+		hello.Features |= gateway.FeatureResume
+		copy(hello.Token[:], slf._opts.ResumeToken)
+	}
+
+	if err := slf._conn.Write(hello.Marshal()); err != nil {
+		return err
+	}
+
+	var rsp *gateway.ServerHello
+	tmpByte := make([]byte, constReadByte)
+	for {
+		var err error
+		if rsp, err = gateway.ReadServerHello(slf._buffer); err == nil {
+			break
+		} else if err != net.ErrAnalysisProceed {
+			return err
+		}
+
+		n, err := slf._conn.Read(tmpByte)
+		if err != nil {
+			if udp && slf._buffer.GetBufferLen() == 0 {
+				return &DialError{Err: err}
+			}
+			return err
+		}
+		slf._buffer.WriteBuffer(tmpByte[:n])
+	}
+
+	if rsp.Code != gateway.HandshakeOK {
+		return &HandshakeError{Code: rsp.Code}
+	}
+
+	codec := &gateway.DefaultFrameCodec{FragmentSize: slf._opts.FragmentSize,
+		MaxMessageSize: slf._opts.MaxMessage,
+		Legacy:         rsp.Version < gateway.ProtocolVersion}
+	if rsp.Compress != gateway.CompressNone {
+		if slf._opts.Compressor == nil || slf._opts.Compressor.ID() != int(rsp.Compress) {
+			return code.ErrCompressUnsupported
+		}
+		codec.Compressor = slf._opts.Compressor
+		codec.Threshold = slf._opts.CompressLimit
+	}
+
+	secretByte := make([]byte, 8)
+	binary.BigEndian.PutUint64(secretByte, kex.Secret(privateKey, rsp.PublicKey))
+	encrypt, err := gateway.NewSessionEncrypt(int(rsp.Cipher), secretByte, false)
+	if err != nil {
+		return err
+	}
+
+	slf._encrypt = encrypt
+	slf._codec = codec
+	slf._version = rsp.Version
+	slf._resumed = rsp.Features&gateway.FeatureResume != 0
+	return nil
+}
+
+func (slf *Client) asyncRead() {
+	defer func() {
+		slf._encrypt.Destory()
+		if slf._recv != nil {
+			close(slf._recv)
+		}
+		slf._wait.Done()
+	}()
+
+	tmpByte := make([]byte, constReadByte)
+	for {
+		for {
+			name, data, err := slf._codec.Decode(slf.getEncrypt(), slf._buffer)
+			if err == net.ErrAnalysisProceed {
+				break
+			}
+
+			if err == nil {
+				err = slf.dispatch(name, data)
+			}
+
+			if err != nil {
+				slf.fail(err)
+				return
+			}
+		}
+
+		n, err := slf._conn.Read(tmpByte)
+		if err != nil {
+			slf.fail(err)
+			return
+		}
+		slf._buffer.WriteBuffer(tmpByte[:n])
+	}
+}
+
+//dispatch unmarshal frame data and deliver messages, empty name is a batch container
+func (slf *Client) dispatch(name string, data []byte) error {
+	if len(name) == 0 {
+		return gateway.UnpackBatch(data, slf.dispatch)
+	}
+
+	msgType, err := slf.getMessageType(name)
+	if err != nil {
+		return err
+	}
+
+	msg := reflect.New(msgType.Elem()).Interface().(proto.Message)
+	if err = proto.Unmarshal(data, msg); err != nil {
+		return code.ErrDataCorrupted
+	}
+
+	if token, ok := msg.(*gateway.ResumeToken); ok {
+		slf._errSync.Lock()
+		slf._token = token.Token
+		slf._errSync.Unlock()
+		return nil
+	}
+
+	if slf._opts.OnReceive != nil {
+		slf._opts.OnReceive(msg)
+		return nil
+	}
+
+	select {
+	case slf._recv <- msg:
+		return nil
+	case <-slf._closed:
+		return code.ErrConnectClosed
+	}
+}
+
+func (slf *Client) fail(err error) {
+	slf._errSync.Lock()
+	if slf._err == nil {
+		slf._err = err
+	}
+	slf._errSync.Unlock()
+	slf._conn.Close()
+}
+
+func (slf *Client) getMessageType(name string) (reflect.Type, error) {
+	if slf._opts.FrameMode == gateway.IDFrame {
+		id, err := slf._opts.Registry.DecodeID(name)
+		if err != nil {
+			return nil, err
+		}
+
+		msgType, _ := slf._opts.Registry.GetType(id)
+		if msgType == nil {
+			return nil, code.ErrMessageUndefined
+		}
+		return msgType, nil
+	}
+
+	msgType := proto.MessageType(name)
+	if msgType == nil {
+		return nil, code.ErrMessageUndefined
+	}
+	return msgType, nil
+}
+
+func (slf *Client) getMessageName(msg proto.Message) (string, error) {
+	if slf._opts.FrameMode == gateway.IDFrame {
+		return slf._opts.Registry.EncodeID(msg)
+	}
+	return proto.MessageName(msg), nil
+}
+
+func (slf *Client) getEncrypt() encryption.INetEncryption {
+	if slf._opts.Encrypt {
+		return slf._encrypt
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package gwclient
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/rudp"
+)
+
+//conn gateway transport
+type conn interface {
+	Read(b []byte) (int, error)
+	Write(b []byte) error
+	SetDeadline(t time.Time) error
+	Close() error
+}
+
+//dial connect to gateway, ws:// and wss:// address is websocket, udp:// is reliable udp, otherwise tcp.
+//tls:// is tcp of tls, the config is default when it is not set
+func dial(addr string, timeout time.Duration, tlsConfig *tls.Config) (conn, error) {
+	if strings.HasPrefix(addr, "ws://") || strings.HasPrefix(addr, "wss://") {
+		dialer := &websocket.Dialer{HandshakeTimeout: timeout, TLSClientConfig: tlsConfig}
+		c, _, err := dialer.Dial(addr, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &wsConn{_c: c}, nil
+	}
+
+	if strings.HasPrefix(addr, "udp://") {
+		c, err := rudp.DialTimeout(strings.TrimPrefix(addr, "udp://"), timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &netConn{_c: c}, nil
+	}
+
+	if strings.HasPrefix(addr, "tls://") && tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	addr = strings.TrimPrefix(strings.TrimPrefix(addr, "tcp://"), "tls://")
+	c, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		if tlsConfig.ServerName == "" {
+			//verify the dialed host like tls.Dial
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+
+		tc := tls.Client(c, tlsConfig)
+		tc.SetDeadline(time.Now().Add(timeout))
+		if err = tc.Handshake(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return &netConn{_c: tc}, nil
+	}
+
+	return &netConn{_c: c}, nil
+}
+
+type netConn struct {
+	_c net.Conn
+}
+
+func (slf *netConn) Read(b []byte) (int, error) {
+	return slf._c.Read(b)
+}
+
+func (slf *netConn) Write(b []byte) error {
+	_, err := slf._c.Write(b)
+	return err
+}
+
+func (slf *netConn) SetDeadline(t time.Time) error {
+	return slf._c.SetDeadline(t)
+}
+
+func (slf *netConn) Close() error {
+	return slf._c.Close()
+}
+
+//wsConn websocket transport, binary messages are read as a byte stream
+type wsConn struct {
+	_c *websocket.Conn
+	_r io.Reader
+}
+
+func (slf *wsConn) Read(b []byte) (int, error) {
+	for {
+		if slf._r == nil {
+			_, r, err := slf._c.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			slf._r = r
+		}
+
+		n, err := slf._r.Read(b)
+		if err == io.EOF {
+			slf._r = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (slf *wsConn) Write(b []byte) error {
+	return slf._c.WriteMessage(websocket.BinaryMessage, b)
+}
+
+func (slf *wsConn) SetDeadline(t time.Time) error {
+	if err := slf._c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return slf._c.SetWriteDeadline(t)
+}
+
+func (slf *wsConn) Close() error {
+	return slf._c.Close()
+}
+
+//buffer client receive buffer
+type buffer struct {
+	_data     []byte
+	_cap      int
+	_fragment gateway.FrameFragment
+}
+
+func (slf *buffer) GetBufferCap() int {
+	return slf._cap
+}
+
+func (slf *buffer) GetBufferLen() int {
+	return len(slf._data)
+}
+
+func (slf *buffer) GetBufferBytes() []byte {
+	return slf._data
+}
+
+func (slf *buffer) ClearBuffer() {
+	slf._data = slf._data[:0]
+}
+
+func (slf *buffer) TrunBuffer(n int) {
+	slf._data = slf._data[n:]
+}
+
+func (slf *buffer) WriteBuffer(b []byte) (int, error) {
+	slf._data = append(slf._data, b...)
+	return len(b), nil
+}
+
+//ReadBuffer the result is not overwritten by later writes
+func (slf *buffer) ReadBuffer(n int) []byte {
+	r := slf._data[:n:n]
+	slf._data = slf._data[n:]
+	return r
+}
+
+func (slf *buffer) Fragment() *gateway.FrameFragment {
+	return &slf._fragment
+}
@@ -0,0 +1,408 @@
+package rudp
+
+import (
+	"encoding/binary"
+)
+
+/*********************************************************************************|
+|  32 Bit  |  8 Bit  |  16 Bit  |  32 Bit     |  32 Bit  |  32 Bit  |  16 Bit  |  (N)  |
+|----------|---------|----------|-------------|----------|----------|----------|-------|
+|  Conv    |  Cmd    |  Window  |  Timestamp  |  Serial  |  Una     |  Length  |  Data |
+**********************************************************************************/
+
+const (
+	cmdPush  = 1
+	cmdAck   = 2
+	cmdPing  = 3
+	cmdClose = 4
+)
+
+const (
+	//segment head length
+	constHeadByte = 21
+	//default datagram length
+	constMTU = 1400
+	//default send/receive window in segments
+	constWindow = 256
+	//update interval in milliseconds
+	constInterval = 10
+	//retransmission time out in milliseconds
+	constRtoMin     = 30
+	constRtoDefault = 200
+	constRtoMax     = 10000
+	//resend when skipped by this many acks
+	constFastResend = 2
+	//segment retransmission time outs before the link is dead
+	constDeadLink = 20
+	//window probe interval in milliseconds
+	constProbeInterval = 500
+)
+
+type segment struct {
+	_sn       uint32
+	_ts       uint32
+	_resendts uint32
+	_rto      uint32
+	_fastack  uint32
+	_xmit     uint32
+	_timeouts uint32
+	_data     []byte
+}
+
+type ackItem struct {
+	_sn uint32
+	_ts uint32
+}
+
+//arq reliable, ordered byte stream control block, it is not goroutine safe
+type arq struct {
+	_conv     uint32
+	_mss      int
+	_sndUna   uint32
+	_sndNxt   uint32
+	_rcvNxt   uint32
+	_sndWnd   uint32
+	_rcvWnd   uint32
+	_rmtWnd   uint32
+	_srtt     int32
+	_rttvar   int32
+	_rto      uint32
+	_probets  uint32
+	_sndQueue [][]byte
+	_sndBuf   []*segment
+	_rcvBuf   map[uint32][]byte
+	_rcvQueue []byte
+	_acks     []ackItem
+	_buffer   []byte
+	_dead     bool
+	_closed   bool
+	_output   func([]byte)
+}
+
+func newARQ(conv uint32, mtu int, output func([]byte)) *arq {
+	if mtu <= constHeadByte {
+		mtu = constMTU
+	}
+
+	return &arq{_conv: conv,
+		_mss:    mtu - constHeadByte,
+		_sndWnd: constWindow,
+		_rcvWnd: constWindow,
+		_rmtWnd: constWindow,
+		_rto:    constRtoDefault,
+		_rcvBuf: make(map[uint32][]byte),
+		_buffer: make([]byte, 0, mtu),
+		_output: output}
+}
+
+//getConv Returns conv of a datagram
+func getConv(pkt []byte) (uint32, bool) {
+	if len(pkt) < constHeadByte {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(pkt), true
+}
+
+//send queue stream data, small writes are merged into the last queued segment
+func (slf *arq) send(b []byte) {
+	for len(b) > 0 {
+		if n := len(slf._sndQueue); n > 0 && len(slf._sndQueue[n-1]) < slf._mss {
+			last := slf._sndQueue[n-1]
+			m := slf._mss - len(last)
+			if m > len(b) {
+				m = len(b)
+			}
+			slf._sndQueue[n-1] = append(last, b[:m]...)
+			b = b[m:]
+			continue
+		}
+
+		m := len(b)
+		if m > slf._mss {
+			m = slf._mss
+		}
+		d := make([]byte, m, slf._mss)
+		copy(d, b)
+		slf._sndQueue = append(slf._sndQueue, d)
+		b = b[m:]
+	}
+}
+
+//waitSnd Returns segments not acknowledged
+func (slf *arq) waitSnd() int {
+	return len(slf._sndQueue) + len(slf._sndBuf)
+}
+
+//read take ordered received data
+func (slf *arq) read(b []byte) int {
+	n := copy(b, slf._rcvQueue)
+	slf._rcvQueue = slf._rcvQueue[n:]
+	if len(slf._rcvQueue) == 0 {
+		slf._rcvQueue = nil
+	}
+	return n
+}
+
+//input process a datagram, returns false when the datagram is malformed
+func (slf *arq) input(pkt []byte, now uint32) bool {
+	maxack, hasack := uint32(0), false
+	for len(pkt) > 0 {
+		if len(pkt) < constHeadByte {
+			return false
+		}
+
+		conv := binary.BigEndian.Uint32(pkt)
+		cmd := pkt[4]
+		wnd := binary.BigEndian.Uint16(pkt[5:])
+		ts := binary.BigEndian.Uint32(pkt[7:])
+		sn := binary.BigEndian.Uint32(pkt[11:])
+		una := binary.BigEndian.Uint32(pkt[15:])
+		length := int(binary.BigEndian.Uint16(pkt[19:]))
+		pkt = pkt[constHeadByte:]
+
+		if conv != slf._conv || length > len(pkt) {
+			return false
+		}
+
+		slf._rmtWnd = uint32(wnd)
+		slf.parseUna(una)
+
+		switch cmd {
+		case cmdAck:
+			if diff(now, ts) >= 0 {
+				slf.updateRtt(diff(now, ts))
+			}
+			slf.parseAck(sn)
+			if !hasack || diff(sn, maxack) > 0 {
+				maxack, hasack = sn, true
+			}
+		case cmdPush:
+			if diff(sn, slf._rcvNxt+slf._rcvWnd) < 0 {
+				slf._acks = append(slf._acks, ackItem{_sn: sn, _ts: ts})
+				if diff(sn, slf._rcvNxt) >= 0 {
+					if _, ok := slf._rcvBuf[sn]; !ok {
+						d := make([]byte, length)
+						copy(d, pkt[:length])
+						slf._rcvBuf[sn] = d
+					}
+				}
+			}
+		case cmdPing:
+		case cmdClose:
+			slf._closed = true
+		default:
+			return false
+		}
+
+		pkt = pkt[length:]
+	}
+
+	for {
+		d, ok := slf._rcvBuf[slf._rcvNxt]
+		if !ok {
+			break
+		}
+		delete(slf._rcvBuf, slf._rcvNxt)
+		slf._rcvQueue = append(slf._rcvQueue, d...)
+		slf._rcvNxt++
+	}
+
+	if hasack {
+		for _, seg := range slf._sndBuf {
+			if diff(seg._sn, maxack) < 0 {
+				seg._fastack++
+			}
+		}
+	}
+
+	return true
+}
+
+func (slf *arq) parseUna(una uint32) {
+	n := 0
+	for n < len(slf._sndBuf) && diff(slf._sndBuf[n]._sn, una) < 0 {
+		n++
+	}
+
+	if n > 0 {
+		slf._sndBuf = slf._sndBuf[n:]
+	}
+	slf.shrinkBuf()
+}
+
+func (slf *arq) parseAck(sn uint32) {
+	if diff(sn, slf._sndUna) < 0 || diff(sn, slf._sndNxt) >= 0 {
+		return
+	}
+
+	for i, seg := range slf._sndBuf {
+		if seg._sn == sn {
+			slf._sndBuf = append(slf._sndBuf[:i], slf._sndBuf[i+1:]...)
+			break
+		}
+
+		if diff(sn, seg._sn) < 0 {
+			break
+		}
+	}
+	slf.shrinkBuf()
+}
+
+func (slf *arq) shrinkBuf() {
+	if len(slf._sndBuf) > 0 {
+		slf._sndUna = slf._sndBuf[0]._sn
+	} else {
+		slf._sndUna = slf._sndNxt
+	}
+}
+
+func (slf *arq) updateRtt(rtt int32) {
+	if slf._srtt == 0 {
+		slf._srtt = rtt
+		slf._rttvar = rtt / 2
+	} else {
+		delta := rtt - slf._srtt
+		if delta < 0 {
+			delta = -delta
+		}
+		slf._rttvar = (3*slf._rttvar + delta) / 4
+		slf._srtt = (7*slf._srtt + rtt) / 8
+		if slf._srtt < 1 {
+			slf._srtt = 1
+		}
+	}
+
+	rto := slf._srtt + 4*slf._rttvar
+	if rto < slf._srtt+constInterval {
+		rto = slf._srtt + constInterval
+	}
+
+	if rto < constRtoMin {
+		rto = constRtoMin
+	} else if rto > constRtoMax {
+		rto = constRtoMax
+	}
+	slf._rto = uint32(rto)
+}
+
+//fastInterval minimum interval of fast retransmissions, rto until a round trip is sampled
+func (slf *arq) fastInterval() int32 {
+	if slf._srtt > 0 {
+		return slf._srtt
+	}
+	return int32(slf._rto)
+}
+
+func (slf *arq) unusedWnd() uint16 {
+	used := uint32(len(slf._rcvBuf) + (len(slf._rcvQueue)+slf._mss-1)/slf._mss)
+	if used >= slf._rcvWnd {
+		return 0
+	}
+	return uint16(slf._rcvWnd - used)
+}
+
+//flush send acks, new segments and retransmissions, returns true when data was sent
+func (slf *arq) flush(now uint32) bool {
+	sent := false
+	wnd := slf.unusedWnd()
+
+	for _, ack := range slf._acks {
+		slf.pack(cmdAck, wnd, ack._ts, ack._sn, nil)
+	}
+	slf._acks = slf._acks[:0]
+
+	if slf._rmtWnd == 0 && diff(now, slf._probets) >= 0 {
+		slf._probets = now + constProbeInterval
+		slf.pack(cmdPing, wnd, now, 0, nil)
+	}
+
+	cwnd := slf._sndWnd
+	if slf._rmtWnd < cwnd {
+		cwnd = slf._rmtWnd
+	}
+
+	for len(slf._sndQueue) > 0 && diff(slf._sndNxt, slf._sndUna+cwnd) < 0 {
+		seg := &segment{_sn: slf._sndNxt, _data: slf._sndQueue[0]}
+		slf._sndQueue[0] = nil
+		slf._sndQueue = slf._sndQueue[1:]
+		slf._sndBuf = append(slf._sndBuf, seg)
+		slf._sndNxt++
+	}
+
+	for _, seg := range slf._sndBuf {
+		resend := false
+		if seg._xmit == 0 {
+			seg._rto = slf._rto
+			resend = true
+		} else if diff(now, seg._resendts) >= 0 {
+			seg._rto += seg._rto / 2
+			if seg._rto > constRtoMax {
+				seg._rto = constRtoMax
+			}
+			seg._timeouts++
+			resend = true
+		} else if seg._fastack >= constFastResend && diff(now, seg._ts) >= slf.fastInterval() {
+			//at most once a round trip, acks of the last resend are in flight
+			resend = true
+		}
+
+		if !resend {
+			continue
+		}
+
+		seg._xmit++
+		seg._fastack = 0
+		seg._ts = now
+		seg._resendts = now + seg._rto
+		slf.pack(cmdPush, wnd, now, seg._sn, seg._data)
+		sent = true
+
+		if seg._timeouts >= constDeadLink {
+			slf._dead = true
+		}
+	}
+
+	slf.output()
+	return sent
+}
+
+//ping send a keep alive segment
+func (slf *arq) ping(now uint32) {
+	slf.pack(cmdPing, slf.unusedWnd(), now, 0, nil)
+	slf.output()
+}
+
+//close send a close segment, it is not retransmitted
+func (slf *arq) close(now uint32) {
+	slf.pack(cmdClose, slf.unusedWnd(), now, 0, nil)
+	slf.output()
+}
+
+func (slf *arq) pack(cmd byte, wnd uint16, ts, sn uint32, data []byte) {
+	if len(slf._buffer)+constHeadByte+len(data) > cap(slf._buffer) {
+		slf.output()
+	}
+
+	var head [constHeadByte]byte
+	binary.BigEndian.PutUint32(head[:], slf._conv)
+	head[4] = cmd
+	binary.BigEndian.PutUint16(head[5:], wnd)
+	binary.BigEndian.PutUint32(head[7:], ts)
+	binary.BigEndian.PutUint32(head[11:], sn)
+	binary.BigEndian.PutUint32(head[15:], slf._rcvNxt)
+	binary.BigEndian.PutUint16(head[19:], uint16(len(data)))
+	slf._buffer = append(slf._buffer, head[:]...)
+	slf._buffer = append(slf._buffer, data...)
+}
+
+func (slf *arq) output() {
+	if len(slf._buffer) == 0 {
+		return
+	}
+	slf._output(slf._buffer)
+	slf._buffer = slf._buffer[:0]
+}
+
+func diff(later, earlier uint32) int32 {
+	return int32(later - earlier)
+}
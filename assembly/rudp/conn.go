@@ -0,0 +1,398 @@
+package rudp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	//keep alive interval in milliseconds
+	constKeepAlive = 5000
+	//idle time out in milliseconds
+	constIdleTimeout = 30000
+	//write blocks when this many segments are not acknowledged
+	constSendLimit = constWindow * 2
+	//close waits queued data acknowledged in milliseconds
+	constLinger = 1000
+	//a new connection replaces the one of same address when nothing is received
+	//in milliseconds, live peers ping every keep alive
+	constRestartIdle = constKeepAlive * 2
+)
+
+var (
+	//ErrClosed connection closed
+	ErrClosed = errors.New("Reliable udp closed")
+	//ErrDeadLink peer did not acknowledge
+	ErrDeadLink = errors.New("Reliable udp dead link")
+
+	baseTime = time.Now()
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "Reliable udp i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func currentMs() uint32 {
+	return uint32(time.Since(baseTime) / time.Millisecond)
+}
+
+//Dial doc
+//@Summary connect to a reliable udp listener
+//@Param  address host:port
+//@Return *Conn
+//@Return error
+func Dial(addr string) (*Conn, error) {
+	return DialTimeout(addr, 0)
+}
+
+//DialTimeout doc
+//@Summary connect to a reliable udp listener, the address is resolved in timeout.
+//         There is no connect of udp, the peer is known reachable by its first datagram
+//@Param  address host:port
+//@Param  timeout, 0 is no timeout
+//@Return *Conn
+//@Return error
+func DialTimeout(addr string, timeout time.Duration) (*Conn, error) {
+	uc, err := (&net.Dialer{Timeout: timeout}).Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sock := uc.(*net.UDPConn)
+	raddr := sock.RemoteAddr().(*net.UDPAddr)
+
+	var b [4]byte
+	if _, err = rand.Read(b[:]); err != nil {
+		sock.Close()
+		return nil, err
+	}
+
+	conv := binary.BigEndian.Uint32(b[:]) | 1
+	c := newConn(conv, sock, raddr, nil)
+	go c.asyncRead()
+	go c.asyncUpdate()
+	return c, nil
+}
+
+//Conn doc
+//@Summary reliable udp connection, implements net.Conn
+type Conn struct {
+	_arq       *arq
+	_sock      *net.UDPConn
+	_remote    *net.UDPAddr
+	_parent    *Listener
+	_sync      sync.Mutex
+	_readable  chan struct{}
+	_writable  chan struct{}
+	_closed    chan struct{}
+	_once      sync.Once
+	_err       error
+	_recvts    uint32
+	_sendts    uint32
+	_rdeadline time.Time
+	_wdeadline time.Time
+}
+
+func newConn(conv uint32, sock *net.UDPConn, remote *net.UDPAddr, parent *Listener) *Conn {
+	c := &Conn{_sock: sock,
+		_remote:   remote,
+		_parent:   parent,
+		_readable: make(chan struct{}, 1),
+		_writable: make(chan struct{}, 1),
+		_closed:   make(chan struct{}),
+		_recvts:   currentMs()}
+
+	c._arq = newARQ(conv, constMTU, c.output)
+	return c
+}
+
+//Read doc
+//@Summary read ordered stream data
+func (slf *Conn) Read(b []byte) (int, error) {
+	for {
+		slf._sync.Lock()
+		if n := slf._arq.read(b); n > 0 {
+			slf._sync.Unlock()
+			return n, nil
+		}
+
+		if slf._arq._closed {
+			slf._sync.Unlock()
+			return 0, io.EOF
+		}
+
+		if slf._err != nil {
+			err := slf._err
+			slf._sync.Unlock()
+			return 0, err
+		}
+		deadline := slf._rdeadline
+		slf._sync.Unlock()
+
+		if err := slf.wait(slf._readable, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+//Write doc
+//@Summary write stream data, blocks while too many segments are not acknowledged
+func (slf *Conn) Write(b []byte) (int, error) {
+	for {
+		slf._sync.Lock()
+		if slf._err != nil {
+			err := slf._err
+			slf._sync.Unlock()
+			return 0, err
+		}
+
+		if slf._arq.waitSnd() < constSendLimit {
+			slf._arq.send(b)
+			now := currentMs()
+			if slf._arq.flush(now) {
+				slf._sendts = now
+			}
+			slf._sync.Unlock()
+			return len(b), nil
+		}
+		deadline := slf._wdeadline
+		slf._sync.Unlock()
+
+		if err := slf.wait(slf._writable, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (slf *Conn) wait(event chan struct{}, deadline time.Time) error {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return timeoutError{}
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-event:
+		return nil
+	case <-slf._closed:
+		return nil
+	case <-timeout:
+		return timeoutError{}
+	}
+}
+
+//Close doc
+//@Summary close connection after queued data is acknowledged or linger time out,
+//         the peer is notified. The linger ends at the write deadline
+func (slf *Conn) Close() error {
+	linger := time.Now().Add(constLinger * time.Millisecond)
+	slf._sync.Lock()
+	if !slf._wdeadline.IsZero() && slf._wdeadline.Before(linger) {
+		linger = slf._wdeadline
+	}
+	slf._sync.Unlock()
+	for {
+		slf._sync.Lock()
+		n := slf._arq.waitSnd()
+		broken := slf._err != nil
+		slf._sync.Unlock()
+
+		if n == 0 || broken || slf.wait(slf._writable, linger) != nil {
+			break
+		}
+	}
+
+	slf.shutdown(ErrClosed, true)
+	return nil
+}
+
+//shutdown close connection with a reason
+func (slf *Conn) shutdown(err error, notify bool) {
+	slf._once.Do(func() {
+		slf._sync.Lock()
+		slf._err = err
+		if notify {
+			slf._arq.close(currentMs())
+		}
+		slf._sync.Unlock()
+		close(slf._closed)
+
+		if slf._parent != nil {
+			slf._parent.remove(slf)
+		} else {
+			slf._sock.Close()
+		}
+	})
+}
+
+//LocalAddr doc
+//@Summary Returns local address
+func (slf *Conn) LocalAddr() net.Addr {
+	return slf._sock.LocalAddr()
+}
+
+//RemoteAddr doc
+//@Summary Returns remote address
+func (slf *Conn) RemoteAddr() net.Addr {
+	return slf._remote
+}
+
+//SetDeadline doc
+//@Summary Set read and write deadline, blocked read and write are waked to check it
+func (slf *Conn) SetDeadline(t time.Time) error {
+	slf._sync.Lock()
+	slf._rdeadline = t
+	slf._wdeadline = t
+	slf._sync.Unlock()
+	notify(slf._readable)
+	notify(slf._writable)
+	return nil
+}
+
+//SetReadDeadline doc
+//@Summary Set read deadline, blocked read is waked to check it
+func (slf *Conn) SetReadDeadline(t time.Time) error {
+	slf._sync.Lock()
+	slf._rdeadline = t
+	slf._sync.Unlock()
+	notify(slf._readable)
+	return nil
+}
+
+//SetWriteDeadline doc
+//@Summary Set write deadline, blocked write is waked to check it
+func (slf *Conn) SetWriteDeadline(t time.Time) error {
+	slf._sync.Lock()
+	slf._wdeadline = t
+	slf._sync.Unlock()
+	notify(slf._writable)
+	return nil
+}
+
+func (slf *Conn) output(b []byte) {
+	if slf._parent != nil {
+		slf._sock.WriteToUDP(b, slf._remote)
+		return
+	}
+	slf._sock.Write(b)
+}
+
+//input process a received datagram
+func (slf *Conn) input(pkt []byte) {
+	slf._sync.Lock()
+	now := currentMs()
+	if !slf._arq.input(pkt, now) {
+		slf._sync.Unlock()
+		return
+	}
+	slf._recvts = now
+	readable := len(slf._arq._rcvQueue) > 0
+	writable := slf._arq.waitSnd() < constSendLimit
+	closed := slf._arq._closed
+	if len(slf._arq._acks) > 0 {
+		//acknowledge immediately
+		slf._arq.flush(now)
+	}
+	slf._sync.Unlock()
+
+	if closed {
+		slf.shutdown(io.EOF, false)
+		return
+	}
+
+	if readable {
+		notify(slf._readable)
+	}
+
+	if writable {
+		notify(slf._writable)
+	}
+}
+
+//isIdle returns true when nothing is received in restart idle time
+func (slf *Conn) isIdle(now uint32) bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return diff(now, slf._recvts) >= constRestartIdle
+}
+
+//update flush the control block, returns false when the connection is over
+func (slf *Conn) update(now uint32) bool {
+	slf._sync.Lock()
+	if slf._err != nil {
+		slf._sync.Unlock()
+		return false
+	}
+
+	if slf._arq.flush(now) {
+		slf._sendts = now
+	} else if diff(now, slf._sendts) >= constKeepAlive {
+		slf._sendts = now
+		slf._arq.ping(now)
+	}
+
+	dead := slf._arq._dead
+	idle := diff(now, slf._recvts) >= constIdleTimeout
+	slf._sync.Unlock()
+
+	if dead {
+		slf.shutdown(ErrDeadLink, false)
+		return false
+	}
+
+	if idle {
+		slf.shutdown(ErrDeadLink, false)
+		return false
+	}
+	return true
+}
+
+func (slf *Conn) asyncRead() {
+	b := make([]byte, constMTU*2)
+	for {
+		n, err := slf._sock.Read(b)
+		if err != nil {
+			slf.shutdown(err, false)
+			return
+		}
+
+		if conv, ok := getConv(b[:n]); ok && conv == slf._arq._conv {
+			slf.input(b[:n])
+		}
+	}
+}
+
+func (slf *Conn) asyncUpdate() {
+	ticker := time.NewTicker(constInterval * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !slf.update(currentMs()) {
+				return
+			}
+		case <-slf._closed:
+			return
+		}
+	}
+}
+
+func notify(event chan struct{}) {
+	select {
+	case event <- struct{}{}:
+	default:
+	}
+}
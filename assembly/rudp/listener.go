@@ -0,0 +1,182 @@
+package rudp
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	//pending connections not accepted
+	constBacklog = 128
+)
+
+//Listen doc
+//@Summary listen reliable udp connections
+//@Param  address host:port
+//@Param  maximum connections, 0 is unlimited
+//@Return *Listener
+//@Return error
+func Listen(addr string, max int) (*Listener, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sock, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Listener{_sock: sock,
+		_max:    max,
+		_conns:  make(map[string]*Conn),
+		_accept: make(chan *Conn, constBacklog),
+		_closed: make(chan struct{})}
+
+	go l.asyncRead()
+	go l.asyncUpdate()
+	return l, nil
+}
+
+//Listener doc
+//@Summary reliable udp listener, implements net.Listener
+type Listener struct {
+	_sock   *net.UDPConn
+	_max    int
+	_conns  map[string]*Conn
+	_accept chan *Conn
+	_sync   sync.Mutex
+	_closed chan struct{}
+	_once   sync.Once
+}
+
+//Accept doc
+//@Summary wait and return the next connection
+func (slf *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-slf._accept:
+		return c, nil
+	case <-slf._closed:
+		return nil, ErrClosed
+	}
+}
+
+//Close doc
+//@Summary stop listening and close all connections
+func (slf *Listener) Close() error {
+	slf._once.Do(func() {
+		close(slf._closed)
+		slf._sync.Lock()
+		conns := make([]*Conn, 0, len(slf._conns))
+		for _, c := range slf._conns {
+			conns = append(conns, c)
+		}
+		slf._sync.Unlock()
+
+		for _, c := range conns {
+			c.shutdown(ErrClosed, true)
+		}
+		slf._sock.Close()
+	})
+	return nil
+}
+
+//Addr doc
+//@Summary Returns listen address
+func (slf *Listener) Addr() net.Addr {
+	return slf._sock.LocalAddr()
+}
+
+func (slf *Listener) remove(c *Conn) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	key := c._remote.String()
+	if v, ok := slf._conns[key]; ok && v == c {
+		delete(slf._conns, key)
+	}
+}
+
+func (slf *Listener) asyncRead() {
+	b := make([]byte, constMTU*2)
+	for {
+		n, addr, err := slf._sock.ReadFromUDP(b)
+		if err != nil {
+			slf.Close()
+			return
+		}
+
+		conv, ok := getConv(b[:n])
+		if !ok {
+			continue
+		}
+
+		key := addr.String()
+		slf._sync.Lock()
+		c, ok := slf._conns[key]
+		if ok && c._arq._conv != conv {
+			//a spoofed opening segment must not tear down a live connection
+			if !isOpening(b[:n]) || !c.isIdle(currentMs()) {
+				slf._sync.Unlock()
+				continue
+			}
+			//peer restarted from the same address
+			delete(slf._conns, key)
+			go c.shutdown(ErrClosed, false)
+			ok = false
+		}
+
+		if !ok {
+			if !isOpening(b[:n]) || (slf._max > 0 && len(slf._conns) >= slf._max) {
+				slf._sync.Unlock()
+				continue
+			}
+
+			c = newConn(conv, slf._sock, addr, slf)
+			select {
+			case slf._accept <- c:
+				slf._conns[key] = c
+			default:
+				slf._sync.Unlock()
+				continue
+			}
+		}
+		slf._sync.Unlock()
+
+		c.input(b[:n])
+	}
+}
+
+func (slf *Listener) asyncUpdate() {
+	ticker := time.NewTicker(constInterval * time.Millisecond)
+	defer ticker.Stop()
+
+	conns := make([]*Conn, 0, 64)
+	for {
+		select {
+		case <-ticker.C:
+		case <-slf._closed:
+			return
+		}
+
+		slf._sync.Lock()
+		for _, c := range slf._conns {
+			conns = append(conns, c)
+		}
+		slf._sync.Unlock()
+
+		now := currentMs()
+		for i, c := range conns {
+			c.update(now)
+			conns[i] = nil
+		}
+		conns = conns[:0]
+	}
+}
+
+//isOpening first segment of a connection
+func isOpening(pkt []byte) bool {
+	return pkt[4] == cmdPush && binary.BigEndian.Uint32(pkt[11:]) == 0
+}
@@ -1 +1 @@
-protoc -I=. -I=%GOPATH%\src --gogoslick_out=. sign.proto
\ No newline at end of file
+protoc -I=. -I=%GOPATH%\src --gogoslick_out=. sign.proto deliver.proto
\ No newline at end of file
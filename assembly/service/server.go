@@ -4,10 +4,14 @@ import (
 	"errors"
 	"sync"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/yamakiller/magicNet/handler/net"
 	rpcsrv "github.com/yamakiller/magicRpc/assembly/server"
 )
 
+//DeliverMethod gateway method delivering DeliverReq to clients
+const DeliverMethod = "deliverCtrl.Deliver"
+
 //Options Service Server Options
 type Options struct {
 	Name         string
@@ -139,20 +143,78 @@ func (slf *Server) PutCtrl(ctrl interface{}) error {
 
 //Call call object client function
 func (slf *Server) Call(client uint64, method string, param interface{}) error {
+	handle := slf.getLink(client)
+	if handle == 0 {
+		return errors.New("unknown client")
+	}
+	return slf._rpcServer.Call(handle, method, param)
+}
+
+//SendToClient deliver a message to a gateway client, the gateway link is picked by the client handle
+func (slf *Server) SendToClient(client uint64, msg proto.Message) error {
+	handle := slf.getLink(client)
+	if handle == 0 {
+		return errors.New("unknown client")
+	}
 
-	var handle uint64
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return slf._rpcServer.Call(handle, DeliverMethod, &DeliverReq{Agreement: proto.MessageName(msg),
+		Data:          data,
+		ClientHandles: []uint64{client}})
+}
+
+//SendToClients deliver a message to gateway clients, one call for each gateway link.
+//Returns handles of clients without gateway link or of link calls failed, and error of marshal
+func (slf *Server) SendToClients(clients []uint64, msg proto.Message) ([]uint64, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []uint64
+	links := make(map[uint64]*DeliverReq)
+	for _, client := range clients {
+		handle := slf.getLink(client)
+		if handle == 0 {
+			failed = append(failed, client)
+			continue
+		}
+
+		req, ok := links[handle]
+		if !ok {
+			req = &DeliverReq{Agreement: proto.MessageName(msg), Data: data}
+			links[handle] = req
+		}
+		req.ClientHandles = append(req.ClientHandles, client)
+	}
+
+	for handle, req := range links {
+		if slf._rpcServer.Call(handle, DeliverMethod, req) != nil {
+			failed = append(failed, req.ClientHandles...)
+		}
+	}
+	return failed, nil
+}
+
+//getLink returns gateway link socket handle of client
+func (slf *Server) getLink(client uint64) uint64 {
 	slf._sync.RLock()
+	defer slf._sync.RUnlock()
+
+	if slf._compare == nil {
+		return 0
+	}
+
 	for clientHandle, clientSocketHandle := range slf._ss {
 		if slf._compare(clientHandle, client) == 0 {
-			handle = clientSocketHandle
-			break
+			return clientSocketHandle
 		}
 	}
-	slf._sync.Unlock()
-	if handle == 0 {
-		return errors.New("unknown client")
-	}
-	return slf._rpcServer.Call(handle, method, param)
+	return 0
 }
 
 func (slf *Server) asyncAccept(socketHandle uint64) {
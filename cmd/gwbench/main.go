@@ -0,0 +1,207 @@
+//gwbench simulate game clients against a gateway server.
+//
+//Every connection dials the gateway, completes the handshake and replays
+//the message script, steps with an expected response are timed as round trip.
+//Message types used by the script must be linked into gwbench, game protocols
+//are imported below next to the service protocol.
+//
+//  gwbench -addr 127.0.0.1:8888 -n 2000 -rate 500 -script login.json -duration 60
+//
+//Script file:
+//
+//  {"loops": 10, "steps": [{"send": "service.SignInReq", "body": {"clientHandle": 1},
+//    "expect": "service.SignInRsp", "delay": 100, "timeout": 3000}]}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+
+	_ "github.com/yamakiller/magicGame/assembly/service"
+)
+
+var (
+	addr     = flag.String("addr", "127.0.0.1:8888", "gateway address, tcp://host:port, udp://host:port or ws://host:port/path")
+	conns    = flag.Int("n", 100, "concurrent connections")
+	rate     = flag.Int("rate", 0, "new connections per second, 0 is unlimited")
+	script   = flag.String("script", "", "message script file, empty is connect only")
+	duration = flag.Int64("duration", 0, "replay script until seconds elapsed, 0 is replay script loops")
+	hold     = flag.Int64("hold", 0, "keep connections open milliseconds after script")
+	cipher   = flag.Int("cipher", 1, "session cipher 0:rc4 1:aes-gcm 2:chacha20-poly1305")
+	encrypt  = flag.Bool("encrypt", true, "frames are encrypted")
+	build    = flag.Uint("build", 0, "client build sent in handshake")
+	timeout  = flag.Int64("timeout", 5000, "dial, handshake and response time out in milliseconds")
+)
+
+func main() {
+	flag.Parse()
+
+	s := &Script{Loops: 1}
+	if *script != "" {
+		var err error
+		if s, err = loadScript(*script, *timeout); err != nil {
+			fmt.Fprintf(os.Stderr, "script: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	options := []gwclient.Option{gwclient.WithCipher(*cipher),
+		gwclient.WithEncrypt(*encrypt),
+		gwclient.WithBuild(uint32(*build)),
+		gwclient.WithTimeout(*timeout)}
+
+	var deadline time.Time
+	if *duration > 0 {
+		deadline = time.Now().Add(time.Duration(*duration) * time.Second)
+	}
+
+	c := &collector{}
+	wait := sync.WaitGroup{}
+	start := time.Now()
+
+	var ticker *time.Ticker
+	if *rate > 0 {
+		ticker = time.NewTicker(time.Second / time.Duration(*rate))
+		defer ticker.Stop()
+	}
+
+	for i := 0; i < *conns; i++ {
+		if ticker != nil {
+			<-ticker.C
+		}
+
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			c.merge(runClient(s, deadline, options))
+		}()
+	}
+
+	wait.Wait()
+	c.report(*conns, time.Since(start))
+}
+
+//runClient connect and replay script, returns connection stats
+func runClient(s *Script, deadline time.Time, options []gwclient.Option) *stats {
+	st := &stats{}
+
+	begin := time.Now()
+	cli, err := gwclient.Dial(*addr, options...)
+	if err != nil {
+		switch err.(type) {
+		case *gwclient.DialError:
+			st._dialErr++
+		case *gwclient.HandshakeError:
+			st._shakeErr++
+		default:
+			//options are same of every connection
+			fmt.Fprintf(os.Stderr, "options: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return st
+	}
+	defer cli.Close()
+	st._connect = append(st._connect, time.Since(begin))
+
+	for loop := 0; ; loop++ {
+		if deadline.IsZero() {
+			if loop >= s.Loops {
+				break
+			}
+		} else if time.Now().After(deadline) {
+			break
+		}
+
+		if !replay(cli, s, st) {
+			return st
+		}
+
+		if len(s.Steps) == 0 && !deadline.IsZero() {
+			//connect only, hold until deadline
+			drain(cli, time.Until(deadline), st)
+			break
+		}
+	}
+
+	if *hold > 0 {
+		drain(cli, time.Duration(*hold)*time.Millisecond, st)
+	}
+
+	return st
+}
+
+//replay run script steps once, returns false when connection is broken
+func replay(cli *gwclient.Client, s *Script, st *stats) bool {
+	for _, step := range s.Steps {
+		if step.Delay > 0 {
+			time.Sleep(step.delay())
+		}
+
+		begin := time.Now()
+		if err := cli.Send(step.message()); err != nil {
+			st._sendErr++
+			return false
+		}
+		st._sent++
+
+		if step.Expect == "" {
+			continue
+		}
+
+		if !expect(cli, step.Expect, step.timeout(), st) {
+			return cli.Err() == nil
+		}
+		st._rtt = append(st._rtt, time.Since(begin))
+	}
+
+	return true
+}
+
+//expect wait a message by full name, other messages are counted and dropped
+func expect(cli *gwclient.Client, name string, tm time.Duration, st *stats) bool {
+	timer := time.NewTimer(tm)
+	defer timer.Stop()
+
+	for {
+		select {
+		case msg, ok := <-cli.Recv():
+			if !ok {
+				st._closedErr++
+				return false
+			}
+
+			st._recv++
+			if proto.MessageName(msg) == name {
+				return true
+			}
+		case <-timer.C:
+			st._timeout++
+			return false
+		}
+	}
+}
+
+//drain receive messages for a while
+func drain(cli *gwclient.Client, tm time.Duration, st *stats) {
+	timer := time.NewTimer(tm)
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-cli.Recv():
+			if !ok {
+				st._closedErr++
+				return
+			}
+			st._recv++
+		case <-timer.C:
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+)
+
+//Script doc
+//@Summary message script replayed by every connection
+//@Member  script steps
+//@Member  replay count, ignored when -duration is set
+type Script struct {
+	Steps []*Step `json:"steps"`
+	Loops int     `json:"loops"`
+}
+
+//Step doc
+//@Summary one script step
+//@Member  message full name to send
+//@Member  message body in proto json
+//@Member  message full name expected in response, empty is not wait
+//@Member  delay before send in milliseconds
+//@Member  response time out in milliseconds
+type Step struct {
+	Send    string          `json:"send"`
+	Body    json.RawMessage `json:"body"`
+	Expect  string          `json:"expect"`
+	Delay   int64           `json:"delay"`
+	Timeout int64           `json:"timeout"`
+
+	_msg proto.Message
+}
+
+//message Returns a copy of the step message
+func (slf *Step) message() proto.Message {
+	return proto.Clone(slf._msg)
+}
+
+func (slf *Step) delay() time.Duration {
+	return time.Duration(slf.Delay) * time.Millisecond
+}
+
+func (slf *Step) timeout() time.Duration {
+	return time.Duration(slf.Timeout) * time.Millisecond
+}
+
+//loadScript load and verify script, message types must be linked into gwbench
+func loadScript(path string, timeout int64) (*Script, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	script := &Script{}
+	if err = json.Unmarshal(d, script); err != nil {
+		return nil, err
+	}
+
+	if script.Loops <= 0 {
+		script.Loops = 1
+	}
+
+	for i, step := range script.Steps {
+		msgType := proto.MessageType(step.Send)
+		if msgType == nil {
+			return nil, fmt.Errorf("step %d: %s protocol is undefined", i, step.Send)
+		}
+
+		if step.Expect != "" && proto.MessageType(step.Expect) == nil {
+			return nil, fmt.Errorf("step %d: %s protocol is undefined", i, step.Expect)
+		}
+
+		step._msg = reflect.New(msgType.Elem()).Interface().(proto.Message)
+		if len(step.Body) > 0 {
+			if err = jsonpb.UnmarshalString(string(step.Body), step._msg); err != nil {
+				return nil, fmt.Errorf("step %d: %s", i, err.Error())
+			}
+		}
+
+		if step.Timeout <= 0 {
+			step.Timeout = timeout
+		}
+	}
+
+	return script, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+//stats bench counters, each connection keeps its own and merges on exit
+type stats struct {
+	_connect   []time.Duration
+	_rtt       []time.Duration
+	_sent      int64
+	_recv      int64
+	_dialErr   int64
+	_shakeErr  int64
+	_sendErr   int64
+	_timeout   int64
+	_closedErr int64
+}
+
+func (slf *stats) merge(other *stats) {
+	slf._connect = append(slf._connect, other._connect...)
+	slf._rtt = append(slf._rtt, other._rtt...)
+	slf._sent += other._sent
+	slf._recv += other._recv
+	slf._dialErr += other._dialErr
+	slf._shakeErr += other._shakeErr
+	slf._sendErr += other._sendErr
+	slf._timeout += other._timeout
+	slf._closedErr += other._closedErr
+}
+
+//collector merge connection stats
+type collector struct {
+	_total stats
+	_sync  sync.Mutex
+}
+
+func (slf *collector) merge(s *stats) {
+	slf._sync.Lock()
+	slf._total.merge(s)
+	slf._sync.Unlock()
+}
+
+func (slf *collector) report(conns int, elapsed time.Duration) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	t := &slf._total
+	failed := t._dialErr + t._shakeErr
+	fmt.Printf("connections: %d ok: %d failed: %d (dial: %d handshake: %d)\n",
+		conns, int64(conns)-failed, failed, t._dialErr, t._shakeErr)
+	fmt.Printf("connect latency: %s\n", percentiles(t._connect))
+	fmt.Printf("round trip:      %s\n", percentiles(t._rtt))
+
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	fmt.Printf("messages: sent: %d recv: %d in %s (%.1f sent/s %.1f recv/s)\n",
+		t._sent, t._recv, elapsed.Round(time.Millisecond),
+		float64(t._sent)/seconds, float64(t._recv)/seconds)
+	fmt.Printf("errors: send: %d timeout: %d closed: %d\n", t._sendErr, t._timeout, t._closedErr)
+}
+
+//percentiles format min/p50/p90/p99/max of samples
+func percentiles(samples []time.Duration) string {
+	if len(samples) == 0 {
+		return "no samples"
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	at := func(p float64) time.Duration {
+		i := int(float64(len(samples)-1) * p)
+		return samples[i].Round(time.Microsecond)
+	}
+
+	return fmt.Sprintf("n=%d min=%s p50=%s p90=%s p99=%s max=%s",
+		len(samples), at(0), at(0.5), at(0.9), at(0.99), at(1))
+}
@@ -0,0 +1,17 @@
+module github.com/yamakiller/magicGame
+
+// github.com/yamakiller/magicNet, magicLibs and magicRpc are not pinned yet,
+// resolve them with go get before the first build, see README.md
+
+go 1.20
+
+require (
+	github.com/gogo/protobuf v1.3.2
+	github.com/golang/snappy v0.0.4
+	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.17.11
+	github.com/pierrec/lz4/v4 v4.1.21
+	golang.org/x/crypto v0.31.0
+)
+
+require golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,182 @@
+package test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+	"github.com/yamakiller/magicGame/assembly/rudp"
+)
+
+//TestGatewayBan doc
+func TestGatewayBan(t *testing.T) {
+	srv, err := gateway.New(gateway.WithMaxConnPerIP(4),
+		gateway.WithAcceptRate(10, 20),
+		gateway.WithAutoBan(5, 10000, 60000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addr := range []string{"10.1.2.3/8", "192.168.1.5", "::1"} {
+		if err = srv.Ban(addr, 0); err != nil {
+			t.Fatalf("ban %s: %v", addr, err)
+		}
+	}
+
+	if err = srv.Ban("192.168.1", 0); err != code.ErrBanMalformed {
+		t.Fatalf("malformed ban: %v", err)
+	}
+
+	if err = srv.Ban("172.16.0.1", 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	expect := []string{"10.0.0.0/8", "192.168.1.5/32", "::1/128"}
+	if bans := srv.Bans(); !reflect.DeepEqual(bans, expect) {
+		t.Fatalf("bans: %+v", bans)
+	}
+
+	if err = srv.Unban("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+
+	if bans := srv.Bans(); !reflect.DeepEqual(bans, expect[1:]) {
+		t.Fatalf("unban: %+v", bans)
+	}
+}
+
+//dialRejected returns true when the gateway doesn't complete the handshake
+func dialRejected(addr string) bool {
+	c, err := gwclient.Dial(addr, gwclient.WithTimeout(300))
+	if err != nil {
+		return true
+	}
+	c.Close()
+	return false
+}
+
+//TestGatewayAdmission doc
+func TestGatewayAdmission(t *testing.T) {
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate, gateway.WithMaxConnPerIP(1))
+
+	cli := dialGateway(t, addr)
+	if !dialRejected(addr) {
+		t.Fatal("connection over ip limit")
+	}
+
+	if m := srv.Metrics(); m.AcceptRejected != 1 {
+		t.Fatalf("ip limit metrics: %+v", m)
+	}
+
+	//the connection is uncounted when it is released
+	cli.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for dialRejected(addr) {
+		if time.Now().After(deadline) {
+			t.Fatal("connection of closed client is still counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	srv, addr = listenGateway(t, &gateway.DefaultDelegate{Encrypt: true}, gateway.WithAcceptRate(0.01, 2))
+	dialGateway(t, addr)
+	dialGateway(t, addr)
+	if !dialRejected(addr) {
+		t.Fatal("connection over accept rate")
+	}
+
+	if m := srv.Metrics(); m.AcceptRejected != 1 {
+		t.Fatalf("accept rate metrics: %+v", m)
+	}
+}
+
+//TestGatewayAutoBan doc
+func TestGatewayAutoBan(t *testing.T) {
+	delegate := newRecordDelegate()
+	srv, addr := listenGateway(t, delegate, gateway.WithAutoBan(2, 10000, 300))
+	rawAddr := strings.TrimPrefix(addr, "udp://")
+
+	//frames of messages unknown are not protocol errors
+	for i := 0; i < 3; i++ {
+		raw, err := rudp.Dial(rawAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hello := &gateway.ClientHello{Version: gateway.ProtocolVersion, Cipher: gateway.CipherAESGCM, PublicKey: 1}
+		raw.Write(hello.Marshal())
+		if _, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second); !ok {
+			t.Fatal("raw client handshake")
+		}
+
+		frame, err := (&gateway.DefaultFrameCodec{}).Encode(nil, "test.Unknown", []byte{1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw.Write(frame)
+		if _, ok := delegate.waitState(gateway.StateClosed, 2*time.Second); !ok {
+			t.Fatal("unknown message client is not closed")
+		}
+		raw.Close()
+	}
+
+	if bans := srv.Bans(); len(bans) != 0 {
+		t.Fatalf("banned by unknown messages: %+v", bans)
+	}
+
+	s := dialWait(t, addr, delegate)
+	for i := 0; i < 2; i++ {
+		raw, err := rudp.Dial(rawAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw.Write([]byte{0xff, 0xff, 0xff, 0xff})
+		raw.Close()
+	}
+
+	//clients of the ip are closed by the ban
+	for {
+		c, ok := delegate.waitState(gateway.StateClosed, 2*time.Second)
+		if !ok {
+			t.Fatal("client of banned ip is not closed")
+		}
+		if c._handle == s {
+			break
+		}
+	}
+
+	if bans := srv.Bans(); len(bans) != 1 || bans[0] != "127.0.0.1/32" {
+		t.Fatalf("auto ban: %+v", bans)
+	}
+
+	if m := srv.Metrics(); m.AutoBanned != 1 {
+		t.Fatalf("auto ban metrics: %+v", m)
+	}
+
+	if !dialRejected(addr) {
+		t.Fatal("connection of banned ip")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if bans := srv.Bans(); len(bans) != 0 {
+		t.Fatalf("expired ban: %+v", bans)
+	}
+	dialWait(t, addr, delegate)
+}
+
+//dialWait connect to a gateway and returns the handle of client
+func dialWait(t *testing.T, addr string, delegate *recordDelegate) uint64 {
+	dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+	return s._handle
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+)
+
+//TestGatewayToken doc
+func TestGatewayToken(t *testing.T) {
+	oldKey, key := []byte("old secret"), []byte("secret")
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	token := gateway.SignToken(key, 10086, 2, now+60000)
+
+	userID, auth, expire, err := gateway.VerifyToken(key, token, now)
+	if err != nil || userID != 10086 || auth != 2 || expire != now+60000 {
+		t.Fatalf("verify: %d %d %d %v", userID, auth, expire, err)
+	}
+
+	if _, _, _, err = gateway.VerifyToken(key, token, now+60000); err != code.ErrTokenExpired {
+		t.Fatalf("expired: %v", err)
+	}
+
+	if _, _, _, err = gateway.VerifyToken(oldKey, token, now); err != code.ErrTokenSignature {
+		t.Fatalf("other key: %v", err)
+	}
+
+	tampered := append([]byte{}, token...)
+	tampered[9]++
+	if _, _, _, err = gateway.VerifyToken(key, tampered, now); err != code.ErrTokenSignature {
+		t.Fatalf("tampered: %v", err)
+	}
+
+	if _, _, _, err = gateway.VerifyToken(key, token[:20], now); err != code.ErrTokenMalformed {
+		t.Fatalf("malformed: %v", err)
+	}
+
+	//tokens of the old key are accepted while rotating
+	a := gateway.NewTokenAuthenticator(key, oldKey)
+	req := &gateway.LoginReq{Token: gateway.SignToken(oldKey, 1, 3, now+60000)}
+	if !a.IsLogin(req) {
+		t.Fatal("login request")
+	}
+
+	if userID, auth, _, err = a.Authenticate(1, req); err != nil || userID != 1 || auth != 3 {
+		t.Fatalf("authenticate: %d %d %v", userID, auth, err)
+	}
+
+	if _, _, _, err = gateway.NewTokenAuthenticator(key).Authenticate(1, req); err != code.ErrTokenSignature {
+		t.Fatalf("rotated key: %v", err)
+	}
+}
+
+//TestGatewayLogin doc
+func TestGatewayLogin(t *testing.T) {
+	key := []byte("secret")
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate,
+		gateway.WithAuthenticator(gateway.NewTokenAuthenticator(key)),
+		gateway.WithResume(2000, 8))
+
+	cli := dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+
+	login := func(c *gwclient.Client, token []byte) *gateway.LoginRsp {
+		c.Send(&gateway.LoginReq{Token: token})
+		rsp, _ := recvMessage(c, 2*time.Second).(*gateway.LoginRsp)
+		if rsp == nil {
+			t.Fatal("login response")
+		}
+		return rsp
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if rsp := login(cli, gateway.SignToken([]byte("other"), 10086, 2, now+60000)); rsp.Code != gateway.LoginInvalid {
+		t.Fatalf("bad signature: %+v", rsp)
+	}
+
+	if rsp := login(cli, gateway.SignToken(key, 10086, 2, now-1)); rsp.Code != gateway.LoginExpired {
+		t.Fatalf("expired token: %+v", rsp)
+	}
+
+	if state, err := srv.GetState(s._handle); err != nil || state != gateway.StateUnauthenticated {
+		t.Fatalf("rejected login state: %s %v", state, err)
+	}
+
+	if userID, err := srv.GetUserID(s._handle); err != nil || userID != 0 {
+		t.Fatalf("rejected login user: %d %v", userID, err)
+	}
+
+	rsp := login(cli, gateway.SignToken(key, 10086, 2, now+60000))
+	if rsp.Code != gateway.LoginOK || rsp.UserId != 10086 || rsp.Auth != 2 {
+		t.Fatalf("login: %+v", rsp)
+	}
+
+	if state, err := srv.GetState(s._handle); err != nil || state != gateway.StateAuthenticated {
+		t.Fatalf("login state: %s %v", state, err)
+	}
+
+	if userID, err := srv.GetUserID(s._handle); err != nil || userID != 10086 {
+		t.Fatalf("login user: %d %v", userID, err)
+	}
+
+	//resume token is sent after the login response
+	deadline := time.Now().Add(2 * time.Second)
+	for cli.ResumeToken() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	token := cli.ResumeToken()
+	if token == nil {
+		t.Fatal("resume token")
+	}
+	cli.Close()
+
+	resumed := dialGateway(t, addr, gwclient.WithResume(token))
+	if !resumed.Resumed() {
+		t.Fatal("session is not resumed")
+	}
+
+	if state, err := srv.GetState(s._handle); err != nil || state != gateway.StateAuthenticated {
+		t.Fatalf("resumed state: %s %v", state, err)
+	}
+
+	if userID, err := srv.GetUserID(s._handle); err != nil || userID != 10086 {
+		t.Fatalf("resumed user: %d %v", userID, err)
+	}
+
+	//a token is used once
+	if again := dialGateway(t, addr, gwclient.WithResume(token)); again.Resumed() {
+		t.Fatal("resume token used twice")
+	}
+}
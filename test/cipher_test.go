@@ -0,0 +1,52 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+)
+
+//TestGatewayAEAD doc
+func TestGatewayAEAD(t *testing.T) {
+	secret := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	codec := &gateway.DefaultFrameCodec{}
+	for _, mode := range []int{gateway.CipherAESGCM, gateway.CipherChaCha20} {
+		serverEncrypt, err := gateway.NewAEADEncrypt(mode, secret, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clientEncrypt, err := gateway.NewAEADEncrypt(mode, secret, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bf := &df{_data: bytes.NewBuffer([]byte{})}
+		for i := 0; i < 3; i++ {
+			b, err := codec.Encode(serverEncrypt, "ddddtest", []byte("css001-gb-01k2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bf.WriteBuffer(b)
+		}
+
+		for i := 0; i < 3; i++ {
+			name, data, err := codec.Decode(clientEncrypt, bf)
+			if err != nil || name != "ddddtest" || string(data) != "css001-gb-01k2" {
+				t.Fatalf("cipher %d server To Cleint:%s-%s-%+v", mode, name, string(data), err)
+			}
+		}
+
+		b, err := codec.Encode(clientEncrypt, "ddddtest", []byte("css001-gb-01k2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b[len(b)-1] ^= 0xFF
+		bf.WriteBuffer(b)
+		if _, _, err = codec.Decode(serverEncrypt, bf); err != code.ErrFrameAuthFailed {
+			t.Fatalf("cipher %d tampered frame: %+v", mode, err)
+		}
+	}
+}
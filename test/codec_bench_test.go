@@ -0,0 +1,69 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/service"
+)
+
+//BenchmarkGatewayEncodeMarshal doc
+func BenchmarkGatewayEncodeMarshal(b *testing.B) {
+	codec := &gateway.DefaultFrameCodec{}
+	msg := &service.SignInRsp{Code: 1, Message: "css001-gb-01k2"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d, err := proto.Marshal(msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err = codec.Encode(nil, proto.MessageName(msg), d); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkGatewayDecode doc
+func BenchmarkGatewayDecode(b *testing.B) {
+	codec := &gateway.DefaultFrameCodec{}
+	msg := &service.SignInRsp{Code: 1, Message: "css001-gb-01k2"}
+	d, _ := proto.Marshal(msg)
+	frame, _ := codec.Encode(nil, proto.MessageName(msg), d)
+	bf := &df{_data: bytes.NewBuffer(make([]byte, 0, 4096))}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf.WriteBuffer(frame)
+		if _, _, err := codec.Decode(nil, bf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkGatewayEncodeMessage doc
+func BenchmarkGatewayEncodeMessage(b *testing.B) {
+	codec := &gateway.DefaultFrameCodec{}
+	msg := &service.SignInRsp{Code: 1, Message: "css001-gb-01k2"}
+	name := proto.MessageName(msg)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.EncodeMessage(nil, name, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkGatewayEncodeCompress doc
+func BenchmarkGatewayEncodeCompress(b *testing.B) {
+	codec := &gateway.DefaultFrameCodec{Compressor: &gateway.SnappyCompressor{}}
+	msg := &service.SignInRsp{Code: 1, Message: string(bytes.Repeat([]byte("css001-gb-01k2"), 64))}
+	name := proto.MessageName(msg)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.EncodeMessage(nil, name, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
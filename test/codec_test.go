@@ -2,7 +2,16 @@ package test
 
 import (
 	"bytes"
+	"encoding/binary"
+	"math/rand"
 	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicLibs/encryption/dh64"
+	"github.com/yamakiller/magicNet/handler/encryption"
+	"github.com/yamakiller/magicNet/handler/net"
 )
 
 type df struct {
@@ -36,46 +45,335 @@ func (slf *df) ReadBuffer(n int) []byte {
 
 //TestGatewayDecode doc
 func TestGatewayDecode(t *testing.T) {
-	/*
-		ServerKex := &dh64.KeyExchange{P: dh64.DefaultP, G: dh64.DefaultG}
-		ClientKex := &dh64.KeyExchange{P: dh64.DefaultP, G: dh64.DefaultG}
+	ServerKex := &dh64.KeyExchange{P: dh64.DefaultP, G: dh64.DefaultG}
+	ClientKex := &dh64.KeyExchange{P: dh64.DefaultP, G: dh64.DefaultG}
+
+	serverPrvKey, serverPubKey := ServerKex.KeyPair()
+	clientPrvKey, clientPubKey := ClientKex.KeyPair()
+
+	serverSecret := make([]byte, 8)
+	clientSecret := make([]byte, 8)
+
+	binary.BigEndian.PutUint64(serverSecret, ServerKex.Secret(serverPrvKey, clientPubKey))
+	binary.BigEndian.PutUint64(clientSecret, ClientKex.Secret(clientPrvKey, serverPubKey))
+
+	serverEncrypt := &encryption.NetRC4Encrypt{}
+	clientEncrypt := &encryption.NetRC4Encrypt{}
+
+	if err := serverEncrypt.Cipher(serverSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := clientEncrypt.Cipher(clientSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	codec := &gateway.DefaultFrameCodec{}
+
+	serverToClientData := &df{_data: bytes.NewBuffer([]byte{})}
+	serverToClientData._data.Grow(4096)
+
+	serverEData, err := codec.Encode(serverEncrypt, "ddddtest", []byte("css001-gb-01k2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverToClientData.WriteBuffer(serverEData)
+	sToCName, sToCData, sToCErr := codec.Decode(clientEncrypt, serverToClientData)
+	if sToCErr != nil || sToCName != "ddddtest" || string(sToCData) != "css001-gb-01k2" {
+		t.Fatalf("server To Cleint:%s-%s-%+v", sToCName, string(sToCData), sToCErr)
+	}
+
+	clientToServerData := &df{_data: bytes.NewBuffer([]byte{})}
+	clientToServerData._data.Grow(4096)
+
+	clientEData, err := codec.Encode(clientEncrypt, "ddddtest", []byte("css001-gb-01k2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientToServerData.WriteBuffer(clientEData)
+	cToSName, cToSData, cToSErr := codec.Decode(serverEncrypt, clientToServerData)
+	if cToSErr != nil || cToSName != "ddddtest" || string(cToSData) != "css001-gb-01k2" {
+		t.Fatalf("client To Server:%s-%s-%+v", cToSName, string(cToSData), cToSErr)
+	}
+}
+
+//TestGatewayEncodeOverflow doc
+func TestGatewayEncodeOverflow(t *testing.T) {
+	codec := &gateway.DefaultFrameCodec{}
+	if _, err := codec.Encode(nil, string(make([]byte, 256)), nil); err != code.ErrDataNameOverflow {
+		t.Fatalf("name overflow: %+v", err)
+	}
+
+	if _, err := codec.Encode(nil, "replay", make([]byte, gateway.MaxFrameData+1)); err != code.ErrFrameOverflow {
+		t.Fatalf("frame overflow: %+v", err)
+	}
+
+	//compressed data fits a frame, but the peer cannot decompress it
+	codec.Compressor = &gateway.SnappyCompressor{}
+	if _, err := codec.Encode(nil, "replay", make([]byte, gateway.MaxFrameData+1)); err != code.ErrFrameOverflow {
+		t.Fatalf("compressed frame overflow: %+v", err)
+	}
+}
+
+//TestGatewayLegacyFrame doc
+func TestGatewayLegacyFrame(t *testing.T) {
+	codec := &gateway.DefaultFrameCodec{Legacy: true, Compressor: &gateway.SnappyCompressor{}}
+	data := make([]byte, gateway.MaxFrameData+1)
+	rand.Read(data)
 
-		serverPrvKey, serverPubKey := ServerKex.KeyPair()
-		clientPrvKey, clientPubKey := ClientKex.KeyPair()
+	b, err := codec.Encode(nil, "replay", data)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		serverSecret := make([]byte, 8)
-		clientSecret := make([]byte, 8)
+	//24 bit data length and 8 bit data name length of protocol version 1
+	if header := binary.BigEndian.Uint32(b); header != uint32(len(data))<<8|uint32(len("replay")) {
+		t.Fatalf("legacy frame header: %x", header)
+	}
 
-		binary.BigEndian.PutUint64(serverSecret, ServerKex.Secret(serverPrvKey, clientPubKey))
-		binary.BigEndian.PutUint64(clientSecret, ClientKex.Secret(clientPrvKey, serverPubKey))
+	bf := &df{_data: bytes.NewBuffer(b)}
+	name, d, err := codec.Decode(nil, bf)
+	if err != nil || name != "replay" || !bytes.Equal(d, data) {
+		t.Fatalf("legacy frame: %s %+v", name, err)
+	}
 
-		serverEncrypt := &encryption.NetRC4Encrypt{}
-		clientEncrypt := &encryption.NetRC4Encrypt{}
+	if _, err = codec.Encode(nil, "", data); err != code.ErrDataNameEmpty {
+		t.Fatalf("legacy batch frame: %+v", err)
+	}
 
-		if err := serverEncrypt.Cipher(serverSecret); err != nil {
-			fmt.Println(err)
-			return
+	if _, err = codec.Encode(nil, "replay", make([]byte, gateway.MaxLegacyFrameData+1)); err != code.ErrFrameOverflow {
+		t.Fatalf("legacy frame overflow: %+v", err)
+	}
+}
+
+//TestGatewayCompress doc
+func TestGatewayCompress(t *testing.T) {
+	zstdCompressor, err := gateway.NewZstdCompressor(zstd.SpeedDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zstdCompressor.Close()
+
+	data := bytes.Repeat([]byte("inventory-item-0001;"), 512)
+	for _, c := range []gateway.Compressor{&gateway.SnappyCompressor{}, &gateway.LZ4Compressor{}, zstdCompressor} {
+		codec := &gateway.DefaultFrameCodec{Compressor: c, Threshold: 128}
+		b, err := codec.Encode(nil, "inventory", data)
+		if err != nil {
+			t.Fatal(err)
 		}
 
-		if err := clientEncrypt.Cipher(clientSecret); err != nil {
-			fmt.Println(err)
-			return
+		if len(b) >= len(data) {
+			t.Fatalf("compressor %d not compressed %d", c.ID(), len(b))
 		}
 
-		serverToClientData := &df{_data: bytes.NewBuffer([]byte{})}
-		serverToClientData._data.Grow(4096)
+		bf := &df{_data: bytes.NewBuffer(b)}
+		name, d, err := codec.Decode(nil, bf)
+		if err != nil || name != "inventory" || !bytes.Equal(d, data) {
+			t.Fatalf("compressor %d decode %s %+v", c.ID(), name, err)
+		}
+	}
+}
 
-		serverEData := gateway.TestEncoder(serverEncrypt, "ddddtest", []byte("css001-gb-01k2"))
-		serverToClientData.WriteBuffer(serverEData)
-		sToCName, sToCData, sToCErr := gateway.TestDecoder(clientEncrypt, serverToClientData)
-		fmt.Printf("server To Cleint:%s-%s-%+v\n", sToCName, string(sToCData), sToCErr)
+//TestGatewayCompressFragment doc
+func TestGatewayCompressFragment(t *testing.T) {
+	zstdCompressor, err := gateway.NewZstdCompressor(zstd.SpeedDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zstdCompressor.Close()
 
-		clientToServerData := &df{_data: bytes.NewBuffer([]byte{})}
-		clientToServerData._data.Grow(4096)
+	//half random data, the compressed message is still fragmented
+	data := bytes.Repeat([]byte("level-chunk-0001;"), 3*1024*1024/17)
+	rand.New(rand.NewSource(1)).Read(data[:len(data)/2])
+
+	for _, c := range []gateway.Compressor{&gateway.SnappyCompressor{}, &gateway.LZ4Compressor{}, zstdCompressor} {
+		codec := &gateway.DefaultFrameCodec{Compressor: c,
+			Threshold:      128,
+			FragmentSize:   64 * 1024,
+			MaxMessageSize: 4 * 1024 * 1024}
+		b, err := codec.Encode(nil, "level", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(b) >= len(data) || len(b) <= gateway.MaxFrameData/16 {
+			t.Fatalf("compressor %d frames %d", c.ID(), len(b))
+		}
 
-		clientEData := gateway.TestEncoder(clientEncrypt, "ddddtest", []byte("css001-gb-01k2"))
-		clientToServerData.WriteBuffer(clientEData)
-		cToSName, cToSData, cToSErr := gateway.TestDecoder(serverEncrypt, clientToServerData)
-		fmt.Printf("client To Server:%s-%s-%+v\n", cToSName, string(cToSData), cToSErr)*/
+		bf := &fragmentDf{df: df{_data: bytes.NewBuffer(b)}}
+		name, d, err := codec.Decode(nil, bf)
+		if err != nil || name != "level" || !bytes.Equal(d, data) {
+			t.Fatalf("compressor %d decode %s %d %+v", c.ID(), name, len(d), err)
+		}
+
+		codec.MaxMessageSize = len(data) - 1
+		bf = &fragmentDf{df: df{_data: bytes.NewBuffer(b)}}
+		if _, _, err = codec.Decode(nil, bf); err != code.ErrDataOverflow {
+			t.Fatalf("compressor %d decompress limit: %+v", c.ID(), err)
+		}
+	}
+}
+
+type fragmentDf struct {
+	df
+	_fragment gateway.FrameFragment
+}
+
+func (slf *fragmentDf) Fragment() *gateway.FrameFragment {
+	return &slf._fragment
+}
+
+//TestGatewayFragment doc
+func TestGatewayFragment(t *testing.T) {
+	codec := &gateway.DefaultFrameCodec{FragmentSize: 1024, MaxMessageSize: 64 * 1024}
+	data := make([]byte, 10*1024+7)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	b, err := codec.Encode(nil, "replay", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bf := &fragmentDf{df: df{_data: bytes.NewBuffer([]byte{})}}
+	for i := 0; i < len(b); i += 1500 {
+		if _, _, err = codec.Decode(nil, bf); err != net.ErrAnalysisProceed {
+			t.Fatalf("partial fragment: %+v", err)
+		}
+
+		end := i + 1500
+		if end > len(b) {
+			end = len(b)
+		}
+		bf.WriteBuffer(b[i:end])
+	}
+
+	name, d, err := codec.Decode(nil, bf)
+	if err != nil || name != "replay" || !bytes.Equal(d, data) {
+		t.Fatalf("fragment decode %s %d %+v", name, len(d), err)
+	}
+
+	if _, err = codec.Encode(nil, "replay", make([]byte, 64*1024+1)); err != code.ErrDataOverflow {
+		t.Fatalf("fragment overflow: %+v", err)
+	}
+
+	if _, _, err = (&gateway.DefaultFrameCodec{}).Decode(nil, &df{_data: bytes.NewBuffer(b)}); err != code.ErrFragmentUnsupported {
+		t.Fatalf("fragment unsupported: %+v", err)
+	}
+
+	if _, err = gateway.New(gateway.WithFragment(1024, 0)); err != code.ErrFragmentUnsupported {
+		t.Fatalf("fragment without maximum message: %+v", err)
+	}
+
+	if _, err = gateway.New(gateway.WithFrameCodec(codec), gateway.WithFragment(1024, 64*1024)); err != code.ErrFragmentUnsupported {
+		t.Fatalf("fragment of custom codec: %+v", err)
+	}
+}
+
+//TestGatewayBatch doc
+func TestGatewayBatch(t *testing.T) {
+	names := []string{"move", "chat", "move"}
+	datas := [][]byte{[]byte("x=1"), []byte("hello"), {}}
+
+	codec := &gateway.DefaultFrameCodec{}
+	b, err := codec.Encode(nil, "", gateway.PackBatch(names, datas))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, d, err := codec.Decode(nil, &df{_data: bytes.NewBuffer(b)})
+	if err != nil || name != "" {
+		t.Fatalf("batch decode %s %+v", name, err)
+	}
+
+	i := 0
+	err = gateway.UnpackBatch(d, func(name string, data []byte) error {
+		if name != names[i] || !bytes.Equal(data, datas[i]) {
+			t.Fatalf("batch entry %d %s-%s", i, name, string(data))
+		}
+		i++
+		return nil
+	})
+
+	if err != nil || i != len(names) {
+		t.Fatalf("batch unpack %d %+v", i, err)
+	}
+
+	if err = gateway.UnpackBatch(d[:len(d)-2], func(string, []byte) error { return nil }); err != code.ErrDataCorrupted {
+		t.Fatalf("batch truncated: %+v", err)
+	}
+}
+
+//TestGatewayDecodeHostile doc
+func TestGatewayDecodeHostile(t *testing.T) {
+	codec := &gateway.DefaultFrameCodec{}
+
+	//empty name without batch flag
+	if _, _, err := codec.Decode(nil, &df{_data: bytes.NewBuffer([]byte{0x00, 0x00, 0x02, 0x00, 0x01, 0x02})}); err != code.ErrDataNameEmpty {
+		t.Fatalf("empty name: %+v", err)
+	}
+
+	//batch flag with name
+	if _, _, err := codec.Decode(nil, &df{_data: bytes.NewBuffer([]byte{0x80, 0x00, 0x01, 0x01, 'a', 0x01})}); err != code.ErrDataCorrupted {
+		t.Fatalf("batch name: %+v", err)
+	}
+
+	//oversized length is rejected before the data arrives
+	bf := &df{_data: bytes.NewBuffer(make([]byte, 0, 64))}
+	bf.WriteBuffer([]byte{0x1F, 0xFF, 0xFF, 0x01})
+	if _, _, err := codec.Decode(nil, bf); err != code.ErrDataOverflow {
+		t.Fatalf("length overflow: %+v", err)
+	}
+
+	//ciphertext shorter than the tag
+	aead, err := gateway.NewAEADEncrypt(gateway.CipherAESGCM, []byte{1, 2, 3, 4, 5, 6, 7, 8}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = codec.Decode(aead, &df{_data: bytes.NewBuffer([]byte{0x00, 0x00, 0x04, 0x01, 'a', 1, 2, 3, 4})}); err != code.ErrDataTruncated {
+		t.Fatalf("truncated ciphertext: %+v", err)
+	}
+
+	delegate := &gateway.DefaultDelegate{}
+	if _, err = delegate.Unmarshal("undefined", nil); err != code.ErrMessageUndefined {
+		t.Fatalf("undefined message: %+v", err)
+	}
+}
+
+//TestGatewayDecodeStreamPartial doc
+func TestGatewayDecodeStreamPartial(t *testing.T) {
+	secret := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	serverEncrypt, _ := gateway.NewSessionEncrypt(gateway.CipherRC4, secret, true)
+	clientEncrypt, _ := gateway.NewSessionEncrypt(gateway.CipherRC4, secret, false)
+
+	codec := &gateway.DefaultFrameCodec{}
+	var b []byte
+	for i := 0; i < 3; i++ {
+		d, err := codec.Encode(serverEncrypt, "ddddtest", []byte("css001-gb-01k2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = append(b, d...)
+	}
+
+	//one byte per read, the decrypted header is kept by the buffer
+	bf := &fragmentDf{df: df{_data: bytes.NewBuffer(make([]byte, 0, 1024))}}
+	n := 0
+	for _, c := range b {
+		bf.WriteBuffer([]byte{c})
+		name, data, err := codec.Decode(clientEncrypt, bf)
+		if err == net.ErrAnalysisProceed {
+			continue
+		}
+
+		if err != nil || name != "ddddtest" || string(data) != "css001-gb-01k2" {
+			t.Fatalf("partial rc4 frame %d:%s-%s-%+v", n, name, string(data), err)
+		}
+		n++
+	}
 
+	if n != 3 {
+		t.Fatalf("partial rc4 frames %d", n)
+	}
 }
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/service"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+func isDecodeError(err error) bool {
+	switch err {
+	case net.ErrAnalysisProceed,
+		code.ErrDataOverflow,
+		code.ErrDataCorrupted,
+		code.ErrDataNameEmpty,
+		code.ErrDataTruncated,
+		code.ErrCompressUnsupported,
+		code.ErrFragmentUnsupported,
+		code.ErrFrameAuthFailed:
+		return true
+	}
+	return false
+}
+
+//FuzzGatewayDecode doc
+func FuzzGatewayDecode(f *testing.F) {
+	codec := &gateway.DefaultFrameCodec{Compressor: &gateway.SnappyCompressor{},
+		Threshold:      64,
+		FragmentSize:   128,
+		MaxMessageSize: 4096}
+
+	b, _ := codec.Encode(nil, "service.SignInReq", []byte{0x08, 0x01})
+	f.Add(b)
+	b, _ = codec.Encode(nil, "replay", bytes.Repeat([]byte("magic"), 100))
+	f.Add(b)
+	b, _ = codec.Encode(nil, "", gateway.PackBatch([]string{"a", "b"}, [][]byte{{1}, {2, 3}}))
+	f.Add(b)
+	f.Add([]byte{0x00, 0x00, 0x00, 0x00})
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x01})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bf := &fragmentDf{df: df{_data: bytes.NewBuffer(make([]byte, 0, 1024))}}
+		bf.WriteBuffer(data)
+		for i := 0; i <= len(data); i++ {
+			name, d, err := codec.Decode(nil, bf)
+			if err != nil {
+				if !isDecodeError(err) {
+					t.Fatalf("untyped decode error: %+v", err)
+				}
+				return
+			}
+
+			if len(name) == 0 {
+				gateway.UnpackBatch(d, func(string, []byte) error { return nil })
+			}
+		}
+	})
+}
+
+//FuzzGatewayClientHello doc
+func FuzzGatewayClientHello(f *testing.F) {
+	hello := &gateway.ClientHello{Version: gateway.ProtocolVersion,
+		Build:     1,
+		Cipher:    gateway.CipherAESGCM,
+		PublicKey: 0x0102030405060708}
+	f.Add(hello.Marshal())
+	f.Add([]byte{0x4D, 0x47, 0xFF, 0xFF})
+	f.Add([]byte{0x4D, 0x47, 0x00, 0x00})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bf := &df{_data: bytes.NewBuffer(data)}
+		_, err := gateway.ReadClientHello(bf)
+		if err != nil && err != net.ErrAnalysisProceed && err != code.ErrHandshakeMalformed {
+			t.Fatalf("untyped handshake error: %+v", err)
+		}
+	})
+}
+
+//FuzzGatewayUnmarshal doc
+func FuzzGatewayUnmarshal(f *testing.F) {
+	delegate := &gateway.DefaultDelegate{}
+	d, _ := (&service.SignInReq{ClientHandle: 1024}).Marshal()
+	f.Add("service.SignInReq", d)
+	f.Add("service.SignInRsp", []byte{0x0A, 0xFF, 0x01})
+	f.Add("undefined", []byte{})
+
+	f.Fuzz(func(t *testing.T, name string, data []byte) {
+		_, err := delegate.Unmarshal(name, data)
+		if err != nil && err != code.ErrDataCorrupted && err != code.ErrMessageUndefined {
+			t.Fatalf("untyped unmarshal error: %+v", err)
+		}
+	})
+}
@@ -0,0 +1,350 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	stdnet "net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+	"github.com/yamakiller/magicGame/assembly/service"
+	"github.com/yamakiller/magicLibs/encryption/dh64"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+//echoGateway answer the handshake and echo every frame back
+func echoGateway(rw io.ReadWriter, reason uint8) error {
+	bf := &df{_data: bytes.NewBuffer([]byte{})}
+	tmpByte := make([]byte, 4096)
+	read := func() error {
+		n, err := rw.Read(tmpByte)
+		if err != nil {
+			return err
+		}
+		bf.WriteBuffer(tmpByte[:n])
+		return nil
+	}
+
+	var hello *gateway.ClientHello
+	for {
+		var err error
+		if hello, err = gateway.ReadClientHello(bf); err == nil {
+			break
+		} else if err != net.ErrAnalysisProceed {
+			return err
+		}
+
+		if err = read(); err != nil {
+			return err
+		}
+	}
+
+	if reason != gateway.HandshakeOK {
+		rsp := &gateway.ServerHello{Version: gateway.ProtocolVersion, Code: reason}
+		_, err := rw.Write(rsp.Marshal())
+		return err
+	}
+
+	kex := &dh64.KeyExchange{P: dh64.DefaultP, G: dh64.DefaultG}
+	privateKey, publicKey := kex.KeyPair()
+	secret := make([]byte, 8)
+	binary.BigEndian.PutUint64(secret, kex.Secret(privateKey, hello.PublicKey))
+	encrypt, err := gateway.NewSessionEncrypt(int(hello.Cipher), secret, true)
+	if err != nil {
+		return err
+	}
+
+	rsp := &gateway.ServerHello{Version: gateway.ProtocolVersion,
+		Cipher:    hello.Cipher,
+		PublicKey: publicKey}
+	if hello.Token != [16]byte{} {
+		//every token is resumed
+		rsp.Features = hello.Features & gateway.FeatureResume
+	}
+	if _, err = rw.Write(rsp.Marshal()); err != nil {
+		return err
+	}
+
+	codec := &gateway.DefaultFrameCodec{}
+	for {
+		for {
+			name, data, err := codec.Decode(encrypt, bf)
+			if err == net.ErrAnalysisProceed {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			//echo twice, the second one in a batch container
+			d, err := codec.Encode(encrypt, name, data)
+			if err != nil {
+				return err
+			}
+
+			b, err := codec.Encode(encrypt, "", gateway.PackBatch([]string{name}, [][]byte{data}))
+			if err != nil {
+				return err
+			}
+
+			if _, err = rw.Write(append(d, b...)); err != nil {
+				return err
+			}
+		}
+
+		if err := read(); err != nil {
+			return err
+		}
+	}
+}
+
+type wsReadWriter struct {
+	_c *websocket.Conn
+	_r io.Reader
+}
+
+func (slf *wsReadWriter) Read(b []byte) (int, error) {
+	if slf._r == nil {
+		_, r, err := slf._c.NextReader()
+		if err != nil {
+			return 0, err
+		}
+		slf._r = r
+	}
+
+	n, err := slf._r.Read(b)
+	if err == io.EOF {
+		slf._r = nil
+		err = nil
+	}
+	return n, err
+}
+
+func (slf *wsReadWriter) Write(b []byte) (int, error) {
+	return len(b), slf._c.WriteMessage(websocket.BinaryMessage, b)
+}
+
+func testGatewayClientEcho(t *testing.T, addr string, cipher int, options ...gwclient.Option) {
+	options = append([]gwclient.Option{gwclient.WithCipher(cipher), gwclient.WithTimeout(2000)}, options...)
+	cli, err := gwclient.Dial(addr, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	for i := 0; i < 16; i++ {
+		if err = cli.Send(&service.SignInReq{ClientHandle: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+
+		for j := 0; j < 2; j++ {
+			select {
+			case msg := <-cli.Recv():
+				if req, ok := msg.(*service.SignInReq); !ok || req.ClientHandle != uint64(i) {
+					t.Fatalf("echo %d: %+v", i, msg)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("echo %d timeout", i)
+			}
+		}
+	}
+}
+
+//TestGatewayClient doc
+func TestGatewayClient(t *testing.T) {
+	l, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+				echoGateway(c, gateway.HandshakeOK)
+			}()
+		}
+	}()
+
+	for _, cipher := range []int{gateway.CipherRC4, gateway.CipherAESGCM, gateway.CipherChaCha20} {
+		testGatewayClientEcho(t, "tcp://"+l.Addr().String(), cipher)
+	}
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		echoGateway(&wsReadWriter{_c: c}, gateway.HandshakeOK)
+	}))
+	defer srv.Close()
+
+	testGatewayClientEcho(t, "ws://"+strings.TrimPrefix(srv.URL, "http://"), gateway.CipherAESGCM)
+}
+
+//TestGatewayClientReject doc
+func TestGatewayClientReject(t *testing.T) {
+	l, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		echoGateway(c, gateway.HandshakeBuild)
+	}()
+
+	_, err = gwclient.Dial(l.Addr().String(), gwclient.WithTimeout(2000))
+	if e, ok := err.(*gwclient.HandshakeError); !ok || e.Code != gateway.HandshakeBuild || e.Err != nil {
+		t.Fatalf("handshake reject: %+v", err)
+	}
+
+	//connection closed before server hello
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+
+	_, err = gwclient.Dial(l.Addr().String(), gwclient.WithTimeout(2000))
+	if e, ok := err.(*gwclient.HandshakeError); !ok || e.Err == nil {
+		t.Fatalf("handshake failed: %+v", err)
+	}
+
+	addr := l.Addr().String()
+	l.Close()
+	if _, err = gwclient.Dial(addr, gwclient.WithTimeout(2000)); err == nil {
+		t.Fatal("dial closed listener")
+	} else if _, ok := err.(*gwclient.DialError); !ok {
+		t.Fatalf("dial failed: %+v", err)
+	}
+}
+
+//TestGatewayClientDialUDP doc
+func TestGatewayClientDialUDP(t *testing.T) {
+	//a peer never answering the client hello
+	pc, err := stdnet.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	start := time.Now()
+	_, err = gwclient.Dial("udp://"+pc.LocalAddr().String(), gwclient.WithTimeout(300))
+	e, ok := err.(*gwclient.DialError)
+	if !ok {
+		t.Fatalf("dial silent peer: %+v", err)
+	}
+
+	if ne, ok := e.Err.(stdnet.Error); !ok || !ne.Timeout() {
+		t.Fatalf("dial silent peer error: %+v", e.Err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("dial timeout ignored: %v", elapsed)
+	}
+}
+
+//TestGatewayClientResume doc
+func TestGatewayClientResume(t *testing.T) {
+	l, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+				echoGateway(c, gateway.HandshakeOK)
+			}()
+		}
+	}()
+
+	cli, err := gwclient.Dial(l.Addr().String(), gwclient.WithTimeout(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	token := bytes.Repeat([]byte{7}, 16)
+	if cli.Resumed() || cli.ResumeToken() != nil {
+		t.Fatal("resumed without token")
+	}
+
+	//echoed token is kept by client, not delivered
+	cli.Send(&gateway.ResumeToken{Token: token, Grace: 1000})
+	cli.Send(&service.SignInReq{ClientHandle: 1})
+	select {
+	case msg := <-cli.Recv():
+		if _, ok := msg.(*service.SignInReq); !ok {
+			t.Fatalf("token delivered: %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("echo timeout")
+	}
+
+	if !bytes.Equal(cli.ResumeToken(), token) {
+		t.Fatalf("resume token: %+v", cli.ResumeToken())
+	}
+
+	resumed, err := gwclient.Dial(l.Addr().String(), gwclient.WithTimeout(2000), gwclient.WithResume(cli.ResumeToken()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+
+	if !resumed.Resumed() {
+		t.Fatal("session not resumed")
+	}
+}
+
+//TestGatewayClientServer doc
+func TestGatewayClientServer(t *testing.T) {
+	delegate := &gateway.DefaultDelegate{Encrypt: true}
+	delegate.PutLocalCall(&service.SignInReq{}, echoSignIn)
+	_, addr := listenGateway(t, delegate, gateway.WithMinClientBuild(2))
+
+	for _, cipher := range []int{gateway.CipherRC4, gateway.CipherAESGCM, gateway.CipherChaCha20} {
+		cli := dialGateway(t, addr, gwclient.WithCipher(cipher), gwclient.WithBuild(2))
+		for i := 0; i < 4; i++ {
+			if err := cli.Send(&service.SignInReq{ClientHandle: uint64(i)}); err != nil {
+				t.Fatal(err)
+			}
+
+			if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "signed" {
+				t.Fatalf("cipher %d call %d: %+v", cipher, i, rsp)
+			}
+		}
+	}
+
+	_, err := gwclient.Dial(addr, gwclient.WithTimeout(2000), gwclient.WithBuild(1))
+	if e, ok := err.(*gwclient.HandshakeError); !ok || e.Code != gateway.HandshakeBuild {
+		t.Fatalf("handshake reject: %+v", err)
+	}
+}
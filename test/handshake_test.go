@@ -0,0 +1,210 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+	"github.com/yamakiller/magicGame/assembly/rudp"
+	"github.com/yamakiller/magicGame/assembly/service"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+//TestGatewayCompressFallback doc
+func TestGatewayCompressFallback(t *testing.T) {
+	message := strings.Repeat("compressible;", 64)
+	delegate := &gateway.DefaultDelegate{Encrypt: true}
+	delegate.PutLocalCall(&service.SignInReq{}, func(h uint64, req *service.SignInReq) (*service.SignInRsp, error) {
+		return &service.SignInRsp{Message: message}, nil
+	})
+	_, addr := listenGateway(t, delegate, gateway.WithCompression(&gateway.SnappyCompressor{}, 64))
+
+	plain := dialGateway(t, addr)
+	compressed := dialGateway(t, addr, gwclient.WithCompression(&gateway.SnappyCompressor{}, 64))
+	for _, c := range []*gwclient.Client{plain, compressed} {
+		if err := c.Send(&service.SignInReq{}); err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, ok := recvMessage(c, 2*time.Second).(*service.SignInRsp)
+		if !ok || rsp.Message != message {
+			t.Fatalf("response: %+v %+v", rsp, c.Err())
+		}
+	}
+}
+
+//TestGatewayCompressBroadcast doc
+func TestGatewayCompressBroadcast(t *testing.T) {
+	message := strings.Repeat("compressible;", 64)
+	delegate := newRecordDelegate()
+	srv, addr := listenGateway(t, delegate, gateway.WithCompression(&gateway.SnappyCompressor{}, 64))
+
+	//the first client builds the shared frame by its codec
+	clis := []*gwclient.Client{
+		dialGateway(t, addr, gwclient.WithEncrypt(false), gwclient.WithCompression(&gateway.SnappyCompressor{}, 64)),
+		dialGateway(t, addr, gwclient.WithEncrypt(false)),
+		dialGateway(t, addr, gwclient.WithEncrypt(false), gwclient.WithCompression(&gateway.SnappyCompressor{}, 64)),
+	}
+
+	handles := make([]uint64, 0, len(clis))
+	for range clis {
+		s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+		if !ok {
+			t.Fatal("client handshake")
+		}
+		handles = append(handles, s._handle)
+	}
+
+	expect := func(name string) {
+		for i, c := range clis {
+			rsp, ok := recvMessage(c, 2*time.Second).(*service.SignInRsp)
+			if !ok || rsp.Message != message {
+				t.Fatalf("%s client %d: %+v %+v", name, i, rsp, c.Err())
+			}
+		}
+	}
+
+	if failed, err := srv.Multicast(handles, &service.SignInRsp{Message: message}); err != nil || len(failed) != 0 {
+		t.Fatalf("multicast: %+v %v", failed, err)
+	}
+	expect("multicast")
+
+	if failed, err := srv.Broadcast(&service.SignInRsp{Message: message}); err != nil || len(failed) != 0 {
+		t.Fatalf("broadcast: %+v %v", failed, err)
+	}
+	expect("broadcast")
+}
+
+//TestGatewayHandshake doc
+func TestGatewayHandshake(t *testing.T) {
+	hello := &gateway.ClientHello{Version: gateway.ProtocolVersion,
+		Build:     1024,
+		Cipher:    gateway.CipherChaCha20,
+		Compress:  gateway.CompressMask(gateway.CompressSnappy),
+		PublicKey: 0x0102030405060708}
+	b := hello.Marshal()
+
+	bf := &df{_data: bytes.NewBuffer(b[:len(b)-1])}
+	if _, err := gateway.ReadClientHello(bf); err != net.ErrAnalysisProceed {
+		t.Fatalf("partial client hello: %+v", err)
+	}
+
+	bf.WriteBuffer(b[len(b)-1:])
+	r, err := gateway.ReadClientHello(bf)
+	if err != nil || *r != *hello {
+		t.Fatalf("client hello: %+v %+v", r, err)
+	}
+
+	hello.Features = gateway.FeatureResume
+	hello.Token = [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	bf.WriteBuffer(hello.Marshal())
+	if r, err = gateway.ReadClientHello(bf); err != nil || *r != *hello {
+		t.Fatalf("resume client hello: %+v %+v", r, err)
+	}
+
+	//resume feature without token
+	b = hello.Marshal()
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)-4-16))
+	bf.WriteBuffer(b[:len(b)-16])
+	if _, err = gateway.ReadClientHello(bf); err != code.ErrHandshakeMalformed {
+		t.Fatalf("resume client hello without token: %+v", err)
+	}
+
+	rsp := &gateway.ServerHello{Version: gateway.ProtocolVersion, Code: gateway.HandshakeCipher}
+	bf.WriteBuffer(rsp.Marshal())
+	s, err := gateway.ReadServerHello(bf)
+	if err != nil || *s != *rsp {
+		t.Fatalf("server hello: %+v %+v", s, err)
+	}
+
+	bf.WriteBuffer([]byte{0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0})
+	if _, err = gateway.ReadClientHello(bf); err != code.ErrHandshakeMalformed {
+		t.Fatalf("legacy client hello: %+v", err)
+	}
+}
+
+//TestGatewayLegacyHandshake doc
+func TestGatewayLegacyHandshake(t *testing.T) {
+	delegate := newRecordDelegate()
+	srv, addr := listenGateway(t, delegate, gateway.WithCompression(&gateway.SnappyCompressor{}, 64))
+
+	raw, err := rudp.Dial(strings.TrimPrefix(addr, "udp://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+
+	hello := &gateway.ClientHello{Version: 1,
+		Cipher:    gateway.CipherAESGCM,
+		Compress:  gateway.CompressMask(gateway.CompressSnappy),
+		PublicKey: 1}
+	raw.Write(hello.Marshal())
+
+	bf := &df{_data: bytes.NewBuffer(make([]byte, 0, gateway.MaxFrameData*2))}
+	read := func() {
+		raw.SetReadDeadline(time.Now().Add(5 * time.Second))
+		b := make([]byte, 65536)
+		n, err := raw.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bf.WriteBuffer(b[:n])
+	}
+
+	var rsp *gateway.ServerHello
+	for {
+		if rsp, err = gateway.ReadServerHello(bf); err == nil {
+			break
+		} else if err != net.ErrAnalysisProceed {
+			t.Fatal(err)
+		}
+		read()
+	}
+
+	if rsp.Code != gateway.HandshakeOK || rsp.Version != 1 || rsp.Compress != uint8(gateway.CompressNone) {
+		t.Fatalf("server hello: %+v", rsp)
+	}
+
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("legacy client handshake")
+	}
+
+	//a message larger than a frame of protocol version 2 is one frame without fragment
+	message := strings.Repeat("compressible;", gateway.MaxFrameData/13+1)
+	if err = srv.Send(s._handle, &service.SignInRsp{Message: message}); err != nil {
+		t.Fatal(err)
+	}
+
+	codec := &gateway.DefaultFrameCodec{Legacy: true}
+	for {
+		for bf.GetBufferLen() < 4 {
+			read()
+		}
+
+		header := binary.BigEndian.Uint32(bf.GetBufferBytes())
+		if header>>8 <= gateway.MaxFrameData {
+			t.Fatalf("legacy frame header: %x", header)
+		}
+
+		name, data, err := codec.Decode(nil, bf)
+		if err == net.ErrAnalysisProceed {
+			read()
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		m := &service.SignInRsp{}
+		if err = proto.Unmarshal(data, m); err != nil || m.Message != message {
+			t.Fatalf("legacy frame %s: %+v", name, err)
+		}
+		break
+	}
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+	"github.com/yamakiller/magicGame/assembly/service"
+)
+
+//TestTokenBucket doc
+func TestTokenBucket(t *testing.T) {
+	b := gateway.NewTokenBucket(gateway.RateLimit{Rate: 10, Burst: 3})
+	now := time.Now().UnixNano()
+	for i := 0; i < 3; i++ {
+		if !b.Take(now) {
+			t.Fatalf("burst %d", i)
+		}
+	}
+
+	if b.Take(now) {
+		t.Fatal("over burst")
+	}
+
+	//a token every 100ms
+	if b.Take(now+int64(50*time.Millisecond)) || !b.Take(now+int64(100*time.Millisecond)) {
+		t.Fatal("refill")
+	}
+
+	//refill is capped by burst
+	now += int64(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		if !b.Take(now) {
+			t.Fatalf("refill burst %d", i)
+		}
+	}
+
+	if b.Take(now) {
+		t.Fatal("over refill burst")
+	}
+}
+
+//TestGatewayRateLimitOption doc
+func TestGatewayRateLimitOption(t *testing.T) {
+	opts := gateway.Options{}
+	for _, opt := range []gateway.Option{gateway.WithRateLimit(20, 40),
+		gateway.WithAgreementRateLimit(&service.SignInReq{}, 1, 1),
+		gateway.WithRateAction(gateway.RateError)} {
+		if err := opt(&opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if opts.RateLimit.Rate != 20 || opts.RateLimits["service.SignInReq"].Burst != 1 || opts.RateAction != gateway.RateError {
+		t.Fatalf("options: %+v", opts)
+	}
+}
+
+//TestGatewayRateLimit doc
+func TestGatewayRateLimit(t *testing.T) {
+	serve := func(options ...gateway.Option) (*gateway.Server, *recordDelegate, *gwclient.Client) {
+		delegate := newRecordDelegate()
+		delegate.Encrypt = true
+		delegate.PutLocalCall(&service.SignInReq{}, echoSignIn)
+		srv, addr := listenGateway(t, delegate, options...)
+		return srv, delegate, dialGateway(t, addr)
+	}
+
+	signIn := func(c *gwclient.Client, n int) []interface{} {
+		var result []interface{}
+		for i := 0; i < n; i++ {
+			c.Send(&service.SignInReq{})
+		}
+
+		for {
+			msg := recvMessage(c, 200*time.Millisecond)
+			if msg == nil {
+				return result
+			}
+			result = append(result, msg)
+		}
+	}
+
+	//over the limit is dropped
+	srv, _, cli := serve(gateway.WithRateLimit(0.01, 2))
+	if rsp := signIn(cli, 3); len(rsp) != 2 {
+		t.Fatalf("drop: %+v", rsp)
+	}
+
+	if m := srv.Metrics(); m.RateLimited != 1 || m.RateClosed != 0 {
+		t.Fatalf("drop metrics: %+v", m)
+	}
+
+	//the agreement limit doesn't bypass the global limit
+	srv, _, cli = serve(gateway.WithRateLimit(0.01, 2),
+		gateway.WithAgreementRateLimit(&service.SignInReq{}, 0.01, 5),
+		gateway.WithRateAction(gateway.RateError))
+	rsp := signIn(cli, 3)
+	if len(rsp) != 3 {
+		t.Fatalf("error: %+v", rsp)
+	}
+
+	if e, ok := rsp[2].(*gateway.AgreementError); !ok || e.Code != gateway.ErrorRateLimited || e.Agreement != "service.SignInReq" {
+		t.Fatalf("error response: %+v", rsp[2])
+	}
+
+	if m := srv.Metrics(); m.RateLimited != 1 {
+		t.Fatalf("error metrics: %+v", m)
+	}
+
+	//the agreement limit is checked first
+	_, _, cli = serve(gateway.WithRateLimit(0.01, 5),
+		gateway.WithAgreementRateLimit(&service.SignInReq{}, 0.01, 1),
+		gateway.WithRateAction(gateway.RateError))
+	if rsp = signIn(cli, 2); len(rsp) != 2 {
+		t.Fatalf("agreement limit: %+v", rsp)
+	} else if _, ok := rsp[1].(*gateway.AgreementError); !ok {
+		t.Fatalf("agreement limit response: %+v", rsp[1])
+	}
+
+	//over the limit closes the client
+	srv, delegate, cli := serve(gateway.WithRateLimit(0.01, 1), gateway.WithRateAction(gateway.RateClose))
+	cli.Send(&service.SignInReq{})
+	cli.Send(&service.SignInReq{})
+	if _, ok := delegate.waitState(gateway.StateClosed, 2*time.Second); !ok {
+		t.Fatal("client over rate limit is not closed")
+	}
+
+	if m := srv.Metrics(); m.RateLimited != 1 || m.RateClosed != 1 {
+		t.Fatalf("close metrics: %+v", m)
+	}
+}
@@ -0,0 +1,47 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/service"
+)
+
+//TestMessageRegistry doc
+func TestMessageRegistry(t *testing.T) {
+	r := gateway.NewMessageRegistry(gateway.MessageID16)
+	if err := r.Register(1, &service.SignInReq{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Register(1, &service.SignInRsp{}); err != code.ErrMessageIDConflict {
+		t.Fatalf("id conflict: %+v", err)
+	}
+
+	if _, err := r.RegisterHash(&service.SignInReq{}); err != code.ErrMessageRegistered {
+		t.Fatalf("type registered: %+v", err)
+	}
+
+	if err := r.Register(0x10000, &service.SignInRsp{}); err != code.ErrMessageIDOverflow {
+		t.Fatalf("id overflow: %+v", err)
+	}
+
+	id, err := r.RegisterHash(&service.SignInRsp{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := r.GetID(&service.SignInRsp{}); !ok || v != id {
+		t.Fatalf("get id %d-%d", v, id)
+	}
+
+	if _, name := r.GetType(id); name != "service.SignInRsp" {
+		t.Fatalf("get type %s", name)
+	}
+
+	delegate := &gateway.DefaultDelegate{FrameMode: gateway.IDFrame}
+	if _, err = gateway.New(gateway.WithDelegate(delegate)); err != code.ErrMessageUnregistered {
+		t.Fatalf("id frame without registry: %+v", err)
+	}
+}
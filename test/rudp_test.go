@@ -0,0 +1,262 @@
+package test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	stdnet "net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+	"github.com/yamakiller/magicGame/assembly/rudp"
+	"github.com/yamakiller/magicGame/assembly/service"
+)
+
+//lossyProxy forward datagrams between client and listener, drop and delay some of them
+func lossyProxy(t *testing.T, target string, loss float64) (string, func()) {
+	sock, err := stdnet.ListenUDP("udp", &stdnet.UDPAddr{IP: stdnet.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raddr, _ := stdnet.ResolveUDPAddr("udp", target)
+	upstream, err := stdnet.DialUDP("udp", nil, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var client *stdnet.UDPAddr
+	var sync sync.Mutex
+	r := rand.New(rand.NewSource(1))
+	forward := func(b []byte, f func([]byte)) {
+		sync.Lock()
+		drop := r.Float64() < loss
+		delay := time.Duration(r.Intn(5)) * time.Millisecond
+		sync.Unlock()
+		if drop {
+			return
+		}
+
+		d := append([]byte{}, b...)
+		time.AfterFunc(delay, func() { f(d) })
+	}
+
+	go func() {
+		b := make([]byte, 4096)
+		for {
+			n, addr, err := sock.ReadFromUDP(b)
+			if err != nil {
+				return
+			}
+			sync.Lock()
+			client = addr
+			sync.Unlock()
+			forward(b[:n], func(d []byte) { upstream.Write(d) })
+		}
+	}()
+
+	go func() {
+		b := make([]byte, 4096)
+		for {
+			n, err := upstream.Read(b)
+			if err != nil {
+				return
+			}
+			sync.Lock()
+			addr := client
+			sync.Unlock()
+			forward(b[:n], func(d []byte) { sock.WriteToUDP(d, addr) })
+		}
+	}()
+
+	return sock.LocalAddr().String(), func() {
+		sock.Close()
+		upstream.Close()
+	}
+}
+
+//TestReliableUDP doc
+func TestReliableUDP(t *testing.T) {
+	l, err := rudp.Listen("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+
+	data := make([]byte, 256*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	for _, loss := range []float64{0, 0.1} {
+		addr, closeProxy := lossyProxy(t, l.Addr().String(), loss)
+
+		c, err := rudp.Dial(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go func() {
+			for i := 0; i < len(data); i += 1000 {
+				end := i + 1000
+				if end > len(data) {
+					end = len(data)
+				}
+				c.Write(data[i:end])
+			}
+		}()
+
+		c.SetReadDeadline(time.Now().Add(20 * time.Second))
+		echo := make([]byte, len(data))
+		if _, err = io.ReadFull(c, echo); err != nil || !bytes.Equal(echo, data) {
+			t.Fatalf("loss %.2f echo: %+v", loss, err)
+		}
+
+		c.Close()
+		closeProxy()
+	}
+}
+
+//TestGatewayClientUDP doc
+func TestGatewayClientUDP(t *testing.T) {
+	l, err := rudp.Listen("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+				echoGateway(c, gateway.HandshakeOK)
+			}()
+		}
+	}()
+
+	for _, cipher := range []int{gateway.CipherRC4, gateway.CipherChaCha20} {
+		testGatewayClientEcho(t, "udp://"+l.Addr().String(), cipher)
+	}
+}
+
+//TestReliableUDPRestart doc
+func TestReliableUDPRestart(t *testing.T) {
+	l, err := rudp.Listen("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan stdnet.Conn, 2)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+
+	//both connections come from the address of the proxy
+	addr, closeProxy := lossyProxy(t, l.Addr().String(), 0)
+	defer closeProxy()
+
+	echo := func(c *rudp.Conn, data []byte) error {
+		if _, err := c.Write(data); err != nil {
+			return err
+		}
+
+		c.SetReadDeadline(time.Now().Add(2 * time.Second))
+		result := make([]byte, len(data))
+		if _, err := io.ReadFull(c, result); err != nil {
+			return err
+		}
+
+		if !bytes.Equal(result, data) {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+
+	live, err := rudp.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	if err = echo(live, []byte("live")); err != nil {
+		t.Fatal(err)
+	}
+
+	spoof, err := rudp.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spoof.Close()
+	spoof.Write([]byte("spoof"))
+	time.Sleep(50 * time.Millisecond)
+
+	if err = echo(live, []byte("still live")); err != nil {
+		t.Fatalf("live connection replaced: %+v", err)
+	}
+
+	if len(accepted) != 1 {
+		t.Fatalf("accepted %d connections", len(accepted))
+	}
+}
+
+//TestGatewayConnOutFull doc
+func TestGatewayConnOutFull(t *testing.T) {
+	delegate := newRecordDelegate()
+	srv, addr := listenGateway(t, delegate, gateway.WithClientOutChanSize(4))
+
+	//the client does not read, the gateway writer blocks by flow control
+	cli := dialGateway(t, addr, gwclient.WithRecvChanSize(1))
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+
+	msg := &service.SignInRsp{Message: strings.Repeat("x", 1024)}
+	var err error
+	for i := 0; i < 4096 && err == nil; i++ {
+		err = srv.Send(s._handle, msg)
+	}
+
+	if err != code.ErrClientOutFull {
+		t.Fatalf("send to blocked client: %+v", err)
+	}
+
+	if _, ok = delegate.waitState(gateway.StateClosed, 3*time.Second); !ok {
+		t.Fatal("blocked client is not closed")
+	}
+	cli.Close()
+}
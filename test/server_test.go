@@ -0,0 +1,825 @@
+package test
+
+import (
+	stdnet "net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+	"github.com/yamakiller/magicGame/assembly/rudp"
+	"github.com/yamakiller/magicGame/assembly/service"
+	"github.com/yamakiller/magicLibs/encryption/dh64"
+	"github.com/yamakiller/magicNet/handler/net"
+)
+
+//stateChange session state change of a client
+type stateChange struct {
+	_handle uint64
+	_from   gateway.SessionState
+	_to     gateway.SessionState
+}
+
+//recordDelegate default delegate reporting state changes, accepted clients are
+//reported as handshaking to handshaking
+type recordDelegate struct {
+	gateway.DefaultDelegate
+	_states chan stateChange
+}
+
+func newRecordDelegate() *recordDelegate {
+	return &recordDelegate{_states: make(chan stateChange, 64)}
+}
+
+func (slf *recordDelegate) AsyncAccept(c net.INetClient) error {
+	if err := slf.DefaultDelegate.AsyncAccept(c); err != nil {
+		return err
+	}
+	slf.AsyncState(c, gateway.StateHandshaking, gateway.StateHandshaking)
+	return nil
+}
+
+func (slf *recordDelegate) AsyncState(c net.INetClient, from, to gateway.SessionState) {
+	select {
+	case slf._states <- stateChange{_handle: c.GetID(), _from: from, _to: to}:
+	default:
+	}
+}
+
+//waitState returns next state change of the state, false when it is not changed in time
+func (slf *recordDelegate) waitState(to gateway.SessionState, timeout time.Duration) (stateChange, bool) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case s := <-slf._states:
+			if s._to == to {
+				return s, true
+			}
+		case <-deadline:
+			return stateChange{}, false
+		}
+	}
+}
+
+//callbackDelegate record delegate calling back into the server on state changes
+type callbackDelegate struct {
+	*recordDelegate
+	_srv atomic.Value
+}
+
+func (slf *callbackDelegate) AsyncState(c net.INetClient, from, to gateway.SessionState) {
+	if srv, ok := slf._srv.Load().(*gateway.Server); ok {
+		srv.GetState(c.GetID())
+		srv.GetUserID(c.GetID())
+		srv.Send(c.GetID(), &service.SignInRsp{Message: to.String()})
+	}
+	slf.recordDelegate.AsyncState(c, from, to)
+}
+
+//listenGateway start a gateway of the delegate on a local reliable udp address
+func listenGateway(t *testing.T, delegate gateway.IServerDelegate, options ...gateway.Option) (*gateway.Server, string) {
+	var d *gateway.DefaultDelegate
+	switch v := delegate.(type) {
+	case *gateway.DefaultDelegate:
+		d = v
+	case *recordDelegate:
+		d = &v.DefaultDelegate
+	case *callbackDelegate:
+		d = &v.DefaultDelegate
+	}
+
+	if d != nil && d.KeyExc == nil {
+		d.KeyExc = &dh64.KeyExchange{P: dh64.DefaultP, G: dh64.DefaultG}
+	}
+
+	options = append([]gateway.Option{gateway.WithName(t.Name()),
+		gateway.WithDelegate(delegate),
+		gateway.WithGuardInterval(10)}, options...)
+	srv, err := gateway.New(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := stdnet.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "udp://" + pc.LocalAddr().String()
+	pc.Close()
+
+	if err = srv.Listen(addr); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv, addr
+}
+
+//dialGateway connect to a gateway, the client is closed when the test ends
+func dialGateway(t *testing.T, addr string, options ...gwclient.Option) *gwclient.Client {
+	c, err := gwclient.Dial(addr, append([]gwclient.Option{gwclient.WithTimeout(2000)}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+//recvMessage returns next message of client, nil when nothing is received in time
+func recvMessage(c *gwclient.Client, timeout time.Duration) proto.Message {
+	select {
+	case msg := <-c.Recv():
+		return msg
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+//echoSignIn local method answering SignInReq
+func echoSignIn(h uint64, req *service.SignInReq) (*service.SignInRsp, error) {
+	return &service.SignInRsp{Message: "signed"}, nil
+}
+
+//TestGatewayListenScheme doc
+func TestGatewayListenScheme(t *testing.T) {
+	srv, err := gateway.New(gateway.WithName("Gateway/listen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = srv.Listen("quic://127.0.0.1:0"); err != code.ErrListenUnsupported {
+		t.Fatalf("unknown scheme: %+v", err)
+	}
+
+	if err = srv.Listen("wss://127.0.0.1:0"); err != code.ErrTLSUnconfigured {
+		t.Fatalf("wss without tls: %+v", err)
+	}
+}
+
+//TestGatewayListenResult doc
+func TestGatewayListenResult(t *testing.T) {
+	delegate := &gateway.DefaultDelegate{Encrypt: true}
+	delegate.PutLocalCall(&service.SignInReq{}, echoSignIn)
+	srv, addr := listenGateway(t, delegate)
+
+	//the failure is of this listen only
+	if err := srv.Listen(addr); err == nil {
+		t.Fatal("listen address in use")
+	}
+
+	pc, err := stdnet.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := "udp://" + pc.LocalAddr().String()
+	pc.Close()
+
+	if err = srv.Listen(other); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range []string{addr, other} {
+		cli := dialGateway(t, a)
+		cli.Send(&service.SignInReq{})
+		if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "signed" {
+			t.Fatalf("%s call: %+v", a, rsp)
+		}
+	}
+}
+
+//TestGatewayMarshalOnce doc
+func TestGatewayMarshalOnce(t *testing.T) {
+	delegate := &gateway.DefaultDelegate{}
+	m, err := delegate.AsyncMarshal(&service.SignInRsp{Code: 3, Message: "push"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agree, err := delegate.Unmarshal(m.Agreement, m.AgreementData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp, ok := agree.AgreementData.(*service.SignInRsp); !ok || rsp.Code != 3 || rsp.Message != "push" {
+		t.Fatalf("marshaled message: %+v", agree.AgreementData)
+	}
+
+	registry := gateway.NewMessageRegistry(gateway.MessageID16)
+	registry.Register(7, &service.SignInRsp{})
+	delegate = &gateway.DefaultDelegate{FrameMode: gateway.IDFrame, Registry: registry}
+	if m, err = delegate.AsyncMarshal(&service.SignInRsp{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, err := registry.DecodeID(m.Agreement); err != nil || id != 7 {
+		t.Fatalf("marshaled message id: %d %+v", id, err)
+	}
+
+	if _, err = delegate.AsyncMarshal(&service.SignInReq{}); err != code.ErrMessageUnregistered {
+		t.Fatalf("unregistered message: %+v", err)
+	}
+}
+
+//TestServiceSendToClient doc
+func TestServiceSendToClient(t *testing.T) {
+	srv, err := service.New(service.WithName("service/deliver"),
+		service.WithCompare(func(a, b uint64) int { return int(a>>32) - int(b>>32) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = srv.SendToClient(1<<32|1, &service.SignInRsp{}); err == nil {
+		t.Fatal("client without gateway link")
+	}
+}
+
+//TestServiceDeliver doc
+func TestServiceDeliver(t *testing.T) {
+	const unlinked = 9999
+	svc, err := service.New(service.WithName("service/deliver/gateway"),
+		service.WithCompare(func(a, b uint64) int {
+			//one gateway, the unlinked client is of another one
+			if b == unlinked {
+				return 1
+			}
+			return 0
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Shutdown()
+
+	svcAddr := freeTCPAddr(t)
+	if err = svc.Listen(svcAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate)
+	ctrl, err := srv.Control(&gateway.RouteOption{Server: "deliver", ServerAddr: svcAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctrl.Shutdown()
+
+	cli := dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+
+	failed, err := svc.SendToClients([]uint64{unlinked, s._handle}, &service.SignInRsp{Message: "deliver"})
+	if err != nil || len(failed) != 1 || failed[0] != unlinked {
+		t.Fatalf("deliver: %+v %v", failed, err)
+	}
+
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "deliver" {
+		t.Fatalf("deliver receive: %+v", rsp)
+	}
+
+	if err = svc.SendToClient(s._handle, &service.SignInRsp{Message: "single"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "single" {
+		t.Fatalf("deliver receive: %+v", rsp)
+	}
+}
+
+//TestGatewayAgreementTable doc
+func TestGatewayAgreementTable(t *testing.T) {
+	delegate := &gateway.DefaultDelegate{Encrypt: true}
+	if err := delegate.PutAgreement(&service.SignInRsp{}, &gateway.DefaultAgreement{Auth: true}); err != code.ErrAgreementUndefined {
+		t.Fatalf("agreement without method: %+v", err)
+	}
+
+	if err := delegate.PutLocalCall(&service.SignInRsp{}, nil); err != code.ErrAgreementUndefined {
+		t.Fatalf("local call without method: %+v", err)
+	}
+
+	if err := delegate.PutLocalCall(&service.SignInReq{}, echoSignIn); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := delegate.PutAgreement(&service.DeliverReq{}, &gateway.DefaultAgreement{Addr: "backend",
+		RemoteMethod: "routeCtrl.Deliver",
+		Response:     &service.SignInRsp{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(delegate.Maps) != 2 {
+		t.Fatalf("agreements: %+v", delegate.Maps)
+	}
+
+	backend := &routeCtrl{_release: make(chan struct{})}
+	close(backend._release)
+	svcAddr := listenBackend(t, backend)
+
+	srv, addr := listenGateway(t, delegate)
+	ctrl, err := srv.Control(&gateway.RouteOption{Server: "backend", ServerAddr: svcAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Router("backend", "backend/1", ctrl)
+
+	cli := dialGateway(t, addr)
+	cli.Send(&service.SignInReq{})
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "signed" {
+		t.Fatalf("local call: %+v", rsp)
+	}
+
+	cli.Send(&service.DeliverReq{Agreement: "table"})
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "table" {
+		t.Fatalf("route call: %+v", rsp)
+	}
+}
+
+//TestGatewayAuthPolicy doc
+func TestGatewayAuthPolicy(t *testing.T) {
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	delegate.PutAgreement(&service.SignInReq{}, &gateway.DefaultAgreement{MinAuth: 2, LocalMethod: echoSignIn})
+	srv, addr := listenGateway(t, delegate, gateway.WithAuthPolicy(gateway.AuthReject))
+
+	cli := dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+
+	//the agreement under auth level is answered, the client stays connected
+	for _, level := range []int64{0, 1} {
+		if level > 0 {
+			if err := srv.WithCliAuth(s._handle, level); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cli.Send(&service.SignInReq{})
+		rsp, ok := recvMessage(cli, 2*time.Second).(*gateway.AgreementError)
+		if !ok || rsp.Code != gateway.ErrorUnauthorized || rsp.Agreement != proto.MessageName(&service.SignInReq{}) {
+			t.Fatalf("level %d: %+v", level, rsp)
+		}
+	}
+
+	if err := srv.WithCliAuth(s._handle, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	cli.Send(&service.SignInReq{})
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "signed" {
+		t.Fatalf("auth level met: %+v %+v", rsp, cli.Err())
+	}
+}
+
+//TestSessionState doc
+func TestSessionState(t *testing.T) {
+	valid := map[gateway.SessionState][]gateway.SessionState{
+		gateway.StateHandshaking:     {gateway.StateUnauthenticated, gateway.StateAuthenticated, gateway.StateClosed},
+		gateway.StateUnauthenticated: {gateway.StateAuthenticated, gateway.StateDraining, gateway.StateClosed},
+		gateway.StateAuthenticated: {gateway.StateUnauthenticated, gateway.StateAuthenticated,
+			gateway.StateDraining, gateway.StateClosed},
+		gateway.StateDraining: {gateway.StateClosed},
+		gateway.StateClosed:   {},
+	}
+
+	for from, tos := range valid {
+		for to := gateway.StateHandshaking; to <= gateway.StateClosed; to++ {
+			expect := false
+			for _, v := range tos {
+				expect = expect || v == to
+			}
+
+			if from.CanTransit(to) != expect {
+				t.Fatalf("%s => %s: %v", from, to, !expect)
+			}
+		}
+	}
+
+	if gateway.StateDraining.String() != "Draining" || gateway.SessionState(9).String() != "Unknown" {
+		t.Fatal("state name")
+	}
+}
+
+//TestGatewayStateTransit doc
+func TestGatewayStateTransit(t *testing.T) {
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate, gateway.WithDrainTimeout(100))
+
+	expect := func(from, to gateway.SessionState) uint64 {
+		s, ok := delegate.waitState(to, 2*time.Second)
+		if !ok || s._from != from {
+			t.Fatalf("%s => %s: %+v", from, to, s)
+		}
+
+		if state, err := srv.GetState(s._handle); to != gateway.StateClosed && (err != nil || state != to) {
+			t.Fatalf("server state %s: %s %v", to, state, err)
+		}
+		return s._handle
+	}
+
+	dialGateway(t, addr)
+	h := expect(gateway.StateHandshaking, gateway.StateUnauthenticated)
+
+	if err := srv.WithCliAuth(h, 2); err != nil {
+		t.Fatal(err)
+	}
+	expect(gateway.StateUnauthenticated, gateway.StateAuthenticated)
+
+	if err := srv.Drain(h); err != nil {
+		t.Fatal(err)
+	}
+	expect(gateway.StateAuthenticated, gateway.StateDraining)
+
+	if err := srv.WithCliAuth(h, 0); err != code.ErrStateTransition {
+		t.Fatalf("draining client auth: %+v", err)
+	}
+
+	if err := srv.Drain(h); err != code.ErrStateTransition {
+		t.Fatalf("drain twice: %+v", err)
+	}
+
+	//closed by the drain time out
+	expect(gateway.StateDraining, gateway.StateClosed)
+}
+
+//TestGatewayStateCallback doc
+func TestGatewayStateCallback(t *testing.T) {
+	delegate := &callbackDelegate{recordDelegate: newRecordDelegate()}
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate)
+	delegate._srv.Store(srv)
+
+	cli := dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "Unauthenticated" {
+		t.Fatalf("state callback send: %+v", rsp)
+	}
+
+	//the closed state is reported out of the client group lock
+	cli.Close()
+	if c, ok := delegate.waitState(gateway.StateClosed, 2*time.Second); !ok || c._handle != s._handle {
+		t.Fatalf("closed state: %+v", c)
+	}
+
+	if _, err := srv.GetState(s._handle); err != code.ErrClientUndefined {
+		t.Fatalf("closed client: %+v", err)
+	}
+}
+
+//TestGatewayResumePush doc
+func TestGatewayResumePush(t *testing.T) {
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate, gateway.WithResume(2000, 64))
+
+	cli := dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+
+	if err := srv.WithCliAuth(s._handle, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cli.ResumeToken() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	token := cli.ResumeToken()
+	cli.Close()
+
+	if _, ok = delegate.waitState(gateway.StateClosed, 2*time.Second); !ok {
+		t.Fatal("client close")
+	}
+
+	//messages pushed while the client resumes are kept by the session or sent after it
+	const count = 40
+	pushed := make(chan error, 1)
+	go func() {
+		for i := 0; i < count; i++ {
+			if err := srv.Send(s._handle, &service.SignInRsp{Message: strconv.Itoa(i)}); err != nil {
+				pushed <- err
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		pushed <- nil
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	resumed := dialGateway(t, addr, gwclient.WithResume(token))
+	if !resumed.Resumed() {
+		t.Fatal("session is not resumed")
+	}
+
+	if err := <-pushed; err != nil {
+		t.Fatalf("push while resuming: %+v", err)
+	}
+
+	for i := 0; i < count; i++ {
+		rsp, ok := recvMessage(resumed, 2*time.Second).(*service.SignInRsp)
+		if !ok || rsp.Message != strconv.Itoa(i) {
+			t.Fatalf("message %d: %+v", i, rsp)
+		}
+	}
+}
+
+//TestGatewayResumeOption doc
+func TestGatewayResumeOption(t *testing.T) {
+	if _, err := gateway.New(gateway.WithResume(1000, 0)); err != code.ErrResumeBuffer {
+		t.Fatalf("resume without buffer: %+v", err)
+	}
+
+	if _, err := gateway.New(gateway.WithName("Gateway/resume"), gateway.WithResume(0, 0)); err != nil {
+		t.Fatalf("resume disabled: %+v", err)
+	}
+}
+
+//TestGatewayPush doc
+func TestGatewayPush(t *testing.T) {
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate)
+
+	clis := make([]*gwclient.Client, 2)
+	handles := make([]uint64, 2)
+	for i := range clis {
+		clis[i] = dialGateway(t, addr)
+		s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+		if !ok {
+			t.Fatal("client handshake")
+		}
+		handles[i] = s._handle
+	}
+
+	expect := func(c *gwclient.Client, message string) {
+		if rsp, ok := recvMessage(c, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != message {
+			t.Fatalf("%s: %+v", message, rsp)
+		}
+	}
+
+	if err := srv.Send(handles[1], &service.SignInRsp{Message: "send"}); err != nil {
+		t.Fatal(err)
+	}
+	expect(clis[1], "send")
+
+	failed, err := srv.Multicast(append(handles, 9999), &service.SignInRsp{Message: "multicast"})
+	if err != nil || len(failed) != 1 || failed[0] != 9999 {
+		t.Fatalf("multicast unknown: %+v %v", failed, err)
+	}
+	expect(clis[0], "multicast")
+	expect(clis[1], "multicast")
+
+	clis[1].Close()
+	if s, ok := delegate.waitState(gateway.StateClosed, 2*time.Second); !ok || s._handle != handles[1] {
+		t.Fatal("client close")
+	}
+
+	if failed, err = srv.Multicast(handles, &service.SignInRsp{Message: "closed"}); err != nil ||
+		len(failed) != 1 || failed[0] != handles[1] {
+		t.Fatalf("multicast closed: %+v %v", failed, err)
+	}
+	expect(clis[0], "closed")
+
+	if err = srv.Send(handles[1], &service.SignInRsp{}); err != code.ErrClientUndefined {
+		t.Fatalf("send closed: %+v", err)
+	}
+
+	if failed, err = srv.Broadcast(&service.SignInRsp{Message: "broadcast"}); err != nil || len(failed) != 0 {
+		t.Fatalf("broadcast: %+v %v", failed, err)
+	}
+	expect(clis[0], "broadcast")
+}
+
+//TestGatewayTopic doc
+func TestGatewayTopic(t *testing.T) {
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	srv, addr := listenGateway(t, delegate)
+
+	cli := dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+	h := s._handle
+
+	//a client without hello cannot receive messages
+	raw, err := rudp.Dial(strings.TrimPrefix(addr, "udp://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	raw.Write([]byte{0})
+	if s, ok = delegate.waitState(gateway.StateHandshaking, 2*time.Second); !ok {
+		t.Fatal("client accept")
+	}
+	shaking := s._handle
+
+	if err = srv.Subscribe(9999, "room"); err != code.ErrClientUndefined {
+		t.Fatalf("subscribe unknown: %+v", err)
+	}
+
+	if srv.Subscribe(h, "room") != nil || srv.Subscribe(shaking, "room") != nil || srv.Subscribe(h, "lobby") != nil {
+		t.Fatal("subscribe")
+	}
+
+	failed, err := srv.Publish("room", &service.SignInRsp{Message: "room"})
+	if err != nil || len(failed) != 1 || failed[0] != shaking {
+		t.Fatalf("publish: %+v %v", failed, err)
+	}
+
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "room" {
+		t.Fatalf("publish receive: %+v", rsp)
+	}
+
+	srv.Unsubscribe(h, "room")
+	srv.Publish("room", &service.SignInRsp{Message: "unsubscribed"})
+	if msg := recvMessage(cli, 100*time.Millisecond); msg != nil {
+		t.Fatalf("unsubscribed receive: %+v", msg)
+	}
+
+	//subscriptions are dropped with the client
+	cli.Close()
+	if s, ok = delegate.waitState(gateway.StateClosed, 2*time.Second); !ok || s._handle != h {
+		t.Fatal("client close")
+	}
+
+	if failed, err = srv.Publish("lobby", &service.SignInRsp{}); err != nil || len(failed) != 0 {
+		t.Fatalf("publish closed: %+v %v", failed, err)
+	}
+
+	if err = srv.Subscribe(h, "lobby"); err != code.ErrClientUndefined {
+		t.Fatalf("subscribe closed: %+v", err)
+	}
+}
+
+//routeCtrl backend of routed agreements, calls wait until released
+type routeCtrl struct {
+	_release chan struct{}
+}
+
+func (slf *routeCtrl) Deliver(c net.INetClient, req *service.DeliverReq) *service.SignInRsp {
+	<-slf._release
+	return &service.SignInRsp{Message: req.Agreement}
+}
+
+//listenBackend serve a ctrl of routed agreements, the service is shutdown when the test ends
+func listenBackend(t *testing.T, ctrl interface{}) string {
+	svc, err := service.New(service.WithName("service/" + t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(svc.Shutdown)
+
+	svcAddr := freeTCPAddr(t)
+	if err = svc.Listen(svcAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = svc.PutCtrl(ctrl); err != nil {
+		t.Fatal(err)
+	}
+	return svcAddr
+}
+
+//TestGatewayRoute doc
+func TestGatewayRoute(t *testing.T) {
+	backend := &routeCtrl{_release: make(chan struct{})}
+	svcAddr := listenBackend(t, backend)
+
+	var gated int32
+	delegate := &gateway.DefaultDelegate{Encrypt: true}
+	delegate.PutLocalCall(&service.SignInReq{}, echoSignIn)
+	delegate.PutAgreement(&service.DeliverReq{}, &gateway.DefaultAgreement{Addr: "backend",
+		RemoteMethod: "routeCtrl.Deliver",
+		Response:     &service.SignInRsp{}})
+	delegate.PutAgreement(&gateway.LoginRsp{}, &gateway.DefaultAgreement{Auth: true,
+		LocalMethod: func(h uint64, req *gateway.LoginRsp) (*service.SignInRsp, error) {
+			atomic.AddInt32(&gated, 1)
+			return &service.SignInRsp{}, nil
+		}})
+	srv, addr := listenGateway(t, delegate)
+
+	ctrl, err := srv.Control(&gateway.RouteOption{Server: "backend", ServerAddr: svcAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Router("backend", "backend/1", ctrl)
+
+	cli := dialGateway(t, addr)
+	cli.Send(&service.DeliverReq{Agreement: "routed"})
+	cli.Send(&service.SignInReq{})
+
+	//the local agreement is answered while the route is in progress
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "signed" {
+		t.Fatalf("local call: %+v", rsp)
+	}
+
+	close(backend._release)
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "routed" {
+		t.Fatalf("route call: %+v", rsp)
+	}
+
+	cli.Send(&gateway.LoginRsp{})
+	if rsp, ok := recvMessage(cli, 2*time.Second).(*gateway.AgreementError); !ok ||
+		rsp.Code != gateway.ErrorUnauthorized || atomic.LoadInt32(&gated) != 0 {
+		t.Fatalf("auth agreement before login: %+v %d", rsp, atomic.LoadInt32(&gated))
+	}
+}
+
+//TestGatewayAuthLevel doc
+func TestGatewayAuthLevel(t *testing.T) {
+	reply := func(message string) func(uint64, proto.Message) (*service.SignInRsp, error) {
+		return func(uint64, proto.Message) (*service.SignInRsp, error) {
+			return &service.SignInRsp{Message: message}, nil
+		}
+	}
+
+	delegate := newRecordDelegate()
+	delegate.Encrypt = true
+	delegate.PutAgreement(&gateway.LoginRsp{}, &gateway.DefaultAgreement{Auth: true,
+		LocalMethod: func(h uint64, req *gateway.LoginRsp) (*service.SignInRsp, error) { return reply("auth")(h, req) }})
+	delegate.PutAgreement(&service.DeliverReq{}, &gateway.DefaultAgreement{MinAuth: 3,
+		LocalMethod: func(h uint64, req *service.DeliverReq) (*service.SignInRsp, error) { return reply("level")(h, req) }})
+	srv, addr := listenGateway(t, delegate)
+
+	cli := dialGateway(t, addr)
+	s, ok := delegate.waitState(gateway.StateUnauthenticated, 2*time.Second)
+	if !ok {
+		t.Fatal("client handshake")
+	}
+
+	call := func(msg proto.Message, message string) {
+		cli.Send(msg)
+		rsp := recvMessage(cli, 2*time.Second)
+		if message == "" {
+			if e, ok := rsp.(*gateway.AgreementError); !ok || e.Code != gateway.ErrorUnauthorized {
+				t.Fatalf("%s rejected: %+v", proto.MessageName(msg), rsp)
+			}
+			return
+		}
+
+		if r, ok := rsp.(*service.SignInRsp); !ok || r.Message != message {
+			t.Fatalf("%s: %+v", proto.MessageName(msg), rsp)
+		}
+	}
+
+	call(&gateway.LoginRsp{}, "")
+	if m := srv.Metrics(); m.AuthRejected != 1 {
+		t.Fatalf("rejected metric: %+v", m)
+	}
+
+	//any level is authenticated, the minimum level is checked
+	if err := srv.WithCliAuth(s._handle, 1); err != nil {
+		t.Fatal(err)
+	}
+	call(&gateway.LoginRsp{}, "auth")
+	call(&service.DeliverReq{}, "")
+
+	if err := srv.WithCliAuth(s._handle, 3); err != nil {
+		t.Fatal(err)
+	}
+	call(&service.DeliverReq{}, "level")
+
+	if err := srv.WithCliAuth(s._handle, 0); err != nil {
+		t.Fatal(err)
+	}
+	call(&gateway.LoginRsp{}, "")
+
+	if m := srv.Metrics(); m.AuthRejected != 3 {
+		t.Fatalf("rejected metric: %+v", m)
+	}
+
+	if err := srv.WithCliAuth(9999, 1); err != code.ErrClientUndefined {
+		t.Fatalf("unknown client: %+v", err)
+	}
+
+	closeDelegate := newRecordDelegate()
+	closeDelegate.Encrypt = true
+	closeDelegate.PutAgreement(&gateway.LoginRsp{}, &gateway.DefaultAgreement{Auth: true,
+		LocalMethod: func(h uint64, req *gateway.LoginRsp) (*service.SignInRsp, error) { return reply("auth")(h, req) }})
+	closeSrv, closeAddr := listenGateway(t, closeDelegate, gateway.WithAuthPolicy(gateway.AuthClose))
+
+	cli = dialGateway(t, closeAddr)
+	cli.Send(&gateway.LoginRsp{})
+	if _, ok = closeDelegate.waitState(gateway.StateClosed, 2*time.Second); !ok {
+		t.Fatal("unauthorized client is not closed")
+	}
+
+	if m := closeSrv.Metrics(); m.AuthRejected != 1 {
+		t.Fatalf("rejected metric: %+v", m)
+	}
+}
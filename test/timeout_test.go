@@ -0,0 +1,96 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/yamakiller/magicGame/assembly/gateway"
+)
+
+const constBenchClients = 100000
+
+//TestDeadlineQueue doc
+func TestDeadlineQueue(t *testing.T) {
+	q := gateway.NewDeadlineQueue()
+	q.Schedule(1, 300)
+	q.Schedule(2, 100)
+	q.Schedule(3, 200)
+	q.Schedule(1, 50)
+	q.Cancel(3)
+
+	if expired := q.Expired(99); len(expired) != 1 || expired[0] != 1 {
+		t.Fatalf("expired 99: %+v", expired)
+	}
+
+	if expired := q.Expired(1000); len(expired) != 1 || expired[0] != 2 {
+		t.Fatalf("expired 1000: %+v", expired)
+	}
+
+	if q.Len() != 0 {
+		t.Fatalf("len: %d", q.Len())
+	}
+}
+
+func newBenchDeadlines() *gateway.DeadlineQueue {
+	q := gateway.NewDeadlineQueue()
+	for i := 0; i < constBenchClients; i++ {
+		q.Schedule(uint64(i), int64(i))
+	}
+	return q
+}
+
+//BenchmarkDeadlineTick100k guard tick of 100k clients, nothing expires
+func BenchmarkDeadlineTick100k(b *testing.B) {
+	q := newBenchDeadlines()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(q.Expired(-1)) != 0 {
+			b.Fatal("expired")
+		}
+	}
+}
+
+//BenchmarkPollingTick100k guard tick of 100k clients by visiting every client, as the old guard
+func BenchmarkPollingTick100k(b *testing.B) {
+	clients := make(map[uint64]*int64, constBenchClients)
+	for i := 0; i < constBenchClients; i++ {
+		last := int64(1 << 62)
+		clients[uint64(i)] = &last
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, last := range clients {
+			if *last--; *last <= 0 {
+				b.Fatal("expired")
+			}
+		}
+	}
+}
+
+//BenchmarkDeadlineReschedule100k state change of one client among 100k
+func BenchmarkDeadlineReschedule100k(b *testing.B) {
+	q := newBenchDeadlines()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h := uint64(i % constBenchClients)
+		q.Cancel(h)
+		q.Schedule(h, int64(i+constBenchClients))
+	}
+}
+
+//BenchmarkDeadlineExpire100k one client of 100k expires and is scheduled again
+func BenchmarkDeadlineExpire100k(b *testing.B) {
+	q := newBenchDeadlines()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		expired := q.Expired(int64(i))
+		if len(expired) != 1 {
+			b.Fatalf("expired %d: %d", i, len(expired))
+		}
+		q.Schedule(expired[0], int64(i+constBenchClients))
+	}
+}
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicGame/assembly/code"
+	"github.com/yamakiller/magicGame/assembly/gateway"
+	"github.com/yamakiller/magicGame/assembly/gwclient"
+	"github.com/yamakiller/magicGame/assembly/service"
+)
+
+//selfSignedCert generate a certificate of 127.0.0.1, returns pem files and client config trusting it
+func selfSignedCert(t *testing.T) (string, string, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "magicGame test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []stdnet.IP{stdnet.IPv4(127, 0, 0, 1)},
+		BasicConstraintsValid: true,
+		IsCA:                  true}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "gateway-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certFile, keyFile, &tls.Config{RootCAs: pool}
+}
+
+//serveEcho accept connections and echo frames of them
+func serveEcho(l stdnet.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer c.Close()
+			echoGateway(c, gateway.HandshakeOK)
+		}()
+	}
+}
+
+//TestGatewayTLS doc
+func TestGatewayTLS(t *testing.T) {
+	certFile, keyFile, clientConfig := selfSignedCert(t)
+
+	opts := &gateway.Options{}
+	if err := gateway.WithTLS(certFile, keyFile)(opts); err != nil || opts.TLS == nil {
+		t.Fatalf("load certificate: %+v", err)
+	}
+
+	if err := gateway.WithTLS(keyFile, certFile)(&gateway.Options{}); err == nil {
+		t.Fatal("swapped certificate and key accepted")
+	}
+
+	if _, err := gateway.New(gateway.WithSocketMode(gateway.UDPNet),
+		gateway.WithTLSConfig(opts.TLS)); err != code.ErrTLSUnsupported {
+		t.Fatalf("udp with tls: %+v", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", opts.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go serveEcho(l)
+
+	addr := "tcp://" + l.Addr().String()
+	for _, cipher := range []int{gateway.CipherRC4, gateway.CipherAESGCM} {
+		testGatewayClientEcho(t, addr, cipher, gwclient.WithTLS(clientConfig))
+	}
+
+	//untrusted certificate
+	if _, err = gwclient.Dial(addr, gwclient.WithTLS(&tls.Config{}), gwclient.WithTimeout(1000)); err == nil {
+		t.Fatal("untrusted certificate accepted")
+	}
+
+	ws, err := gateway.ListenWebSocket("127.0.0.1:0/game", opts.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	go serveEcho(ws)
+
+	testGatewayClientEcho(t, "wss://"+ws.Addr().String()+"/game", gateway.CipherChaCha20, gwclient.WithTLS(clientConfig))
+
+	if _, err = gwclient.Dial("wss://"+ws.Addr().String()+"/other",
+		gwclient.WithTLS(clientConfig), gwclient.WithTimeout(1000)); err == nil {
+		t.Fatal("websocket path not matched")
+	}
+}
+
+//freeTCPAddr returns a local tcp address not in use
+func freeTCPAddr(t *testing.T) string {
+	l, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+//TestGatewayTLSListen doc
+func TestGatewayTLSListen(t *testing.T) {
+	certFile, keyFile, clientConfig := selfSignedCert(t)
+
+	delegate := &gateway.DefaultDelegate{Encrypt: true}
+	delegate.PutLocalCall(&service.SignInReq{}, echoSignIn)
+	srv, udpAddr := listenGateway(t, delegate, gateway.WithTLS(certFile, keyFile),
+		gateway.WithClientKeepTime(1000))
+
+	tlsAddr := "tls://" + freeTCPAddr(t)
+	if err := srv.Listen(tlsAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	wssAddr := "wss://" + freeTCPAddr(t) + "/game"
+	if err := srv.Listen(wssAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	//tls is of the listener, the reliable udp listener stays plain
+	for _, addr := range []string{udpAddr, tlsAddr, wssAddr} {
+		var options []gwclient.Option
+		if addr != udpAddr {
+			options = append(options, gwclient.WithTLS(clientConfig))
+		}
+
+		cli := dialGateway(t, addr, options...)
+		if err := cli.Send(&service.SignInReq{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp, ok := recvMessage(cli, 2*time.Second).(*service.SignInRsp); !ok || rsp.Message != "signed" {
+			t.Fatalf("%s call: %+v", addr, rsp)
+		}
+	}
+
+	if _, err := gwclient.Dial("tcp://"+strings.TrimPrefix(tlsAddr, "tls://"),
+		gwclient.WithTimeout(1000)); err == nil {
+		t.Fatal("plain client of tls listener")
+	}
+
+	plain, _ := listenGateway(t, &gateway.DefaultDelegate{})
+	if err := plain.Listen("tls://" + freeTCPAddr(t)); err != code.ErrTLSUnconfigured {
+		t.Fatalf("tls without config: %+v", err)
+	}
+}